@@ -0,0 +1,157 @@
+// Package analysis turns text into the terms stored in and looked up from
+// the search index. An Analyzer splits text into words and runs every word
+// through a chain of filters (lowercasing, diacritic folding, stopword
+// removal, stemming), so text is analyzed the same way at index time and
+// at query time.
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is an analyzed term and the position of its word in the text.
+// Positions count every word, including removed stopwords, so phrases can
+// still be matched around them.
+type Token struct {
+	Term     string
+	Position int
+}
+
+// Word is a word of a text and its rune offsets, End exclusive
+type Word struct {
+	Text       string
+	Start, End int
+}
+
+// Filter transforms one term. Returning "" removes the term.
+type Filter func(term string) string
+
+// Analyzer runs the words of a text through a chain of filters
+type Analyzer struct {
+	Filters []Filter
+}
+
+// NewAnalyzer creates an analyzer running the filters in order
+func NewAnalyzer(filters ...Filter) *Analyzer {
+	return &Analyzer{Filters: filters}
+}
+
+// Analyze returns the terms of the text that survive the filters
+func (a *Analyzer) Analyze(text string) []Token {
+	var tokens []Token
+	for pos, word := range Tokenize(text) {
+		if term := a.Term(word); term != "" {
+			tokens = append(tokens, Token{Term: term, Position: pos})
+		}
+	}
+	return tokens
+}
+
+// Terms returns the analyzed terms of the text without their positions
+func (a *Analyzer) Terms(text string) []string {
+	tokens := a.Analyze(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
+
+// Term runs a single word through the filters, returning "" if it is removed
+func (a *Analyzer) Term(word string) string {
+	for _, filter := range a.Filters {
+		if word = filter(word); word == "" {
+			return ""
+		}
+	}
+	return word
+}
+
+// isWordRune reports whether r is part of a word. Combining marks are kept
+// inside words so accents and Arabic diacritics do not split them.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.M, r)
+}
+
+// Tokenize lowercases text and splits it into words
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// Words splits text into lowercased words like Tokenize, keeping the rune
+// offsets of every word in the original text
+func Words(text string) []Word {
+	var words []Word
+	start := -1
+	pos := 0
+	var b strings.Builder
+	for _, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = pos
+				b.Reset()
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else if start >= 0 {
+			words = append(words, Word{Text: b.String(), Start: start, End: pos})
+			start = -1
+		}
+		pos++
+	}
+	if start >= 0 {
+		words = append(words, Word{Text: b.String(), Start: start, End: pos})
+	}
+	return words
+}
+
+// Lowercase is a filter lowercasing the term
+func Lowercase(term string) string {
+	return strings.ToLower(term)
+}
+
+// foldings maps the Latin letters with diacritics to their base letters
+var foldings = map[rune]string{}
+
+func init() {
+	for base, letters := range map[string]string{
+		"a": "àáâãäåāăą", "ae": "æ", "c": "çćĉċč", "d": "ďđð", "e": "èéêëēĕėęě",
+		"g": "ĝğġģ", "h": "ĥħ", "i": "ìíîïĩīĭįı", "ij": "ĳ", "j": "ĵ", "k": "ķ",
+		"l": "ĺļľŀł", "n": "ñńņňŉ", "o": "òóôõöøōŏő", "oe": "œ", "r": "ŕŗř",
+		"s": "śŝşšſ", "ss": "ß", "t": "ţťŧ", "th": "þ", "u": "ùúûüũūŭůűų",
+		"w": "ŵ", "y": "ýÿŷ", "z": "źżž",
+	} {
+		for _, r := range letters {
+			foldings[r] = base
+		}
+	}
+}
+
+// FoldDiacritics is a filter replacing Latin letters with diacritics by
+// their base letters and dropping combining marks, so "Pré" matches "pre"
+func FoldDiacritics(term string) string {
+	folded := true
+	for _, r := range term {
+		if _, ok := foldings[r]; ok || unicode.Is(unicode.Mn, r) {
+			folded = false
+			break
+		}
+	}
+	if folded {
+		return term
+	}
+
+	var b strings.Builder
+	for _, r := range term {
+		switch base, ok := foldings[r]; {
+		case ok:
+			b.WriteString(base)
+		case unicode.Is(unicode.Mn, r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
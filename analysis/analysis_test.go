@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		language string
+		options  Options
+		text     string
+		want     string
+	}{
+		{English, Options{Stem: true}, "Running runs RUN", "run run run"},
+		{English, Options{Stem: true}, "Harry Potter and the Ponies", "harri potter and the poni"},
+		{English, Options{Stopwords: true, Stem: true}, "The Lord of the Rings", "lord ring"},
+		{"", Options{Stopwords: true, Stem: true}, "The Lord of the Rings", "the lord of the rings"},
+		{"", Options{}, "Pré GrandPré Ærøskøbing", "pre grandpre aeroskobing"},
+		{"", Options{}, "Pré", "pre"},
+		{Spanish, Options{Stopwords: true, Stem: true}, "Cien años de soledad", "cien anos soledad"},
+		{Spanish, Options{Stem: true}, "libros libro libra", "libr libr libr"},
+		{French, Options{Stopwords: true, Stem: true}, "Les Misérables", "miserabl"},
+		{German, Options{Stopwords: true, Stem: true}, "Die Kinder und Häuser", "kind haus"},
+	}
+	for _, test := range tests {
+		got := strings.Join(Chain(test.language, test.options).Terms(test.text), " ")
+		if got != test.want {
+			t.Errorf("Chain(%q, %+v).Terms(%q) = %q, want %q", test.language, test.options, test.text, got, test.want)
+		}
+	}
+}
+
+func TestAnalyzePositions(t *testing.T) {
+	tokens := Chain(English, Options{Stopwords: true}).Analyze("lord of the rings")
+	if len(tokens) != 2 || tokens[0].Position != 0 || tokens[1].Position != 3 {
+		t.Fatalf("Analyze kept %+v, want lord at 0 and rings at 3", tokens)
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	for code, want := range map[string]string{"eng": English, "en-US": English, "spa": Spanish, "fre": French, "ger": German, "jpn": ""} {
+		if got := Language(code); got != want {
+			t.Errorf("Language(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestNormalizeArabic(t *testing.T) {
+	tests := map[string]string{
+		"مَكْتَبَةُ": "مكتبه",
+		"مـكـتـبـة":  "مكتبه",
+		"الإسكندرية": "الاسكندريه",
+		"أحمد":       "احمد",
+		"آمال":       "امال",
+		"إلى":        "الي",
+		"٢٠٢٤":       "2024",
+		"harry":      "harry",
+	}
+	for term, want := range tests {
+		if got := NormalizeArabic(term); got != want {
+			t.Errorf("NormalizeArabic(%q) = %q, want %q", term, got, want)
+		}
+	}
+}
+
+func TestArabizi(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"7abibi", []string{"حبيبي", "habibi"}},
+		{"habibi", []string{"حبيبي", "هبيبي"}},
+		{"3a2ed", []string{"عائد", "aed"}},
+		{"ghornata", []string{"غرناطه"}},
+		{"shams", []string{"شمس"}},
+	}
+	for _, test := range tests {
+		got := Arabizi(test.word)
+		for _, want := range test.want {
+			if !contains(got, want) {
+				t.Errorf("Arabizi(%q) = %q, want it to contain %q", test.word, got, want)
+			}
+		}
+	}
+
+	for _, word := range []string{"a", "2024", "4ever", "café"} {
+		if got := Arabizi(word); got != nil {
+			t.Errorf("Arabizi(%q) = %q, want nothing", word, got)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// arabicLetters maps the Arabic letters with several written forms to the
+// form they are indexed as: alef with hamza or madda to bare alef, taa
+// marbuta to haa and alef maqsura to yaa. Arabic-Indic digits become ASCII
+// digits.
+var arabicLetters = map[rune]rune{
+	'أ': 'ا', 'إ': 'ا', 'آ': 'ا', 'ٱ': 'ا',
+	'ة': 'ه',
+	'ى': 'ي',
+	'٠': '0', '١': '1', '٢': '2', '٣': '3', '٤': '4',
+	'٥': '5', '٦': '6', '٧': '7', '٨': '8', '٩': '9',
+}
+
+// tatweel is the Arabic letter-stretching character
+const tatweel = 'ـ'
+
+// isTashkeel reports whether r is an Arabic diacritic (harakat, tanween,
+// shadda, sukun or superscript alef)
+func isTashkeel(r rune) bool {
+	return (r >= 0x064B && r <= 0x065F) || r == 0x0670
+}
+
+// NormalizeArabic is a filter that unifies the spellings of Arabic words:
+// it removes tatweel and tashkeel, and maps alef variants, taa marbuta and
+// alef maqsura to a single letter each, so "مَكْتَبَة" matches "مكتبه"
+func NormalizeArabic(term string) string {
+	arabic := false
+	for _, r := range term {
+		if unicode.Is(unicode.Arabic, r) {
+			arabic = true
+			break
+		}
+	}
+	if !arabic {
+		return term
+	}
+
+	var b strings.Builder
+	for _, r := range term {
+		if r == tatweel || isTashkeel(r) {
+			continue
+		}
+		if normalized, ok := arabicLetters[r]; ok {
+			r = normalized
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// maxArabiziCandidates caps the Arabic spellings generated for a word
+const maxArabiziCandidates = 64
+
+// arabiziDigits are the digits Arabizi uses for Arabic letters without a
+// Latin equivalent, with the Latin letters they are read as. Hamza and ain
+// are usually left out of Latin spellings, as in "ali" for "3ali".
+var arabiziDigits = map[byte]struct {
+	arabic []string
+	latin  string
+}{
+	'2': {[]string{"ا", "ء", "ئ", "ؤ"}, ""},
+	'3': {[]string{"ع"}, ""},
+	'5': {[]string{"خ"}, "kh"},
+	'6': {[]string{"ط"}, "t"},
+	'7': {[]string{"ح"}, "h"},
+	'8': {[]string{"غ"}, "gh"},
+	'9': {[]string{"ص", "ق"}, "s"},
+}
+
+// arabiziLetters are the Arabic letters Latin letters and digraphs stand
+// for, most likely first
+var arabiziLetters = map[string][]string{
+	"kh": {"خ"}, "gh": {"غ"}, "sh": {"ش"}, "ch": {"ش"}, "th": {"ث", "ت"}, "dh": {"ذ", "ض"},
+	"aa": {"ا"}, "ee": {"ي"}, "ii": {"ي"}, "oo": {"و"}, "ou": {"و"}, "uu": {"و"},
+	"b": {"ب"}, "c": {"ك"}, "d": {"د", "ض"}, "f": {"ف"}, "g": {"ج", "غ"}, "h": {"ه", "ح"},
+	"j": {"ج"}, "k": {"ك", "ق"}, "l": {"ل"}, "m": {"م"}, "n": {"ن"}, "p": {"ب"}, "q": {"ق"},
+	"r": {"ر"}, "s": {"س", "ص"}, "t": {"ت", "ط"}, "v": {"ف"}, "w": {"و"}, "x": {"كس"},
+	"y": {"ي"}, "z": {"ز", "ظ"},
+}
+
+// arabiziVowel returns the letters a short vowel can stand for at a
+// position. Short vowels are usually not written inside Arabic words.
+func arabiziVowel(vowel byte, first, last bool) []string {
+	long := map[byte]string{'a': "ا", 'e': "ي", 'i': "ي", 'o': "و", 'u': "و"}[vowel]
+	switch {
+	case first:
+		return []string{"ا"}
+	case last && vowel == 'a':
+		return []string{"ا", "ه"}
+	case last && vowel == 'e':
+		return []string{"ه", "ي"}
+	case last:
+		return []string{long}
+	case vowel == 'a':
+		return []string{"", "ا"}
+	}
+	return []string{long, ""}
+}
+
+// Arabizi returns the Arabic spellings a word written in Arabizi (Arabic in
+// Latin letters and digits, like "7abibi") may stand for, normalized like
+// NormalizeArabic. If the word uses Arabizi digits, its reading in Latin
+// letters ("habibi") is also returned, last. Words that are not made of
+// ASCII letters and digits return nothing.
+func Arabizi(word string) []string {
+	word = strings.ToLower(word)
+	letters, digits := 0, 0
+	for i := 0; i < len(word); i++ {
+		switch c := word[i]; {
+		case c >= 'a' && c <= 'z':
+			letters++
+		case c >= '0' && c <= '9':
+			if _, ok := arabiziDigits[c]; !ok {
+				return nil
+			}
+			digits++
+		default:
+			return nil
+		}
+	}
+	if letters == 0 || len(word) < 2 {
+		return nil
+	}
+
+	candidates := []string{""}
+	var latin strings.Builder
+	for i := 0; i < len(word); {
+		var options []string
+		n := 1
+		if digit, ok := arabiziDigits[word[i]]; ok {
+			options = digit.arabic
+			latin.WriteString(digit.latin)
+		} else if digraph, ok := arabiziLetters[word[i:min(i+2, len(word))]]; ok && i+1 < len(word) {
+			options, n = digraph, 2
+			latin.WriteString(word[i : i+2])
+		} else if strings.IndexByte("aeiou", word[i]) >= 0 {
+			options = arabiziVowel(word[i], i == 0, i == len(word)-1)
+			latin.WriteByte(word[i])
+		} else {
+			options = arabiziLetters[word[i:i+1]]
+			latin.WriteByte(word[i])
+		}
+		i += n
+
+		next := make([]string, 0, min(len(candidates)*len(options), maxArabiziCandidates))
+		for _, candidate := range candidates {
+			for _, option := range options {
+				if len(next) < maxArabiziCandidates {
+					next = append(next, candidate+option)
+				}
+			}
+		}
+		candidates = next
+	}
+
+	var spellings []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if candidate = NormalizeArabic(candidate); candidate != "" && !seen[candidate] {
+			seen[candidate] = true
+			spellings = append(spellings, candidate)
+		}
+	}
+	if digits > 0 {
+		spellings = append(spellings, latin.String())
+	}
+	return spellings
+}
@@ -0,0 +1,135 @@
+package analysis
+
+import "strings"
+
+// The languages with stopwords and a stemmer
+const (
+	English = "english"
+	Spanish = "spanish"
+	French  = "french"
+	German  = "german"
+)
+
+// Languages lists every language with stopwords and a stemmer
+var Languages = []string{English, Spanish, French, German}
+
+// languageCodes maps the catalog's language codes to languages. English
+// variants such as en-US all use English.
+var languageCodes = map[string]string{
+	"eng": English, "en": English,
+	"spa": Spanish, "es": Spanish,
+	"fre": French, "fra": French, "fr": French,
+	"ger": German, "deu": German, "de": German,
+}
+
+// Language returns the language of a catalog language code, or "" if it has
+// no stemmer
+func Language(code string) string {
+	code = strings.ToLower(code)
+	if strings.HasPrefix(code, "en-") {
+		return English
+	}
+	return languageCodes[code]
+}
+
+// Stemmer returns the stemming filter of the language, or nil if it has none
+func Stemmer(language string) Filter {
+	switch language {
+	case English:
+		return porter
+	case Spanish:
+		return spanishStem
+	case French:
+		return frenchStem
+	case German:
+		return germanStem
+	}
+	return nil
+}
+
+// Options configures which steps of the chain a field uses. Tokenizing,
+// lowercasing, diacritic folding and Arabic normalization always apply.
+type Options struct {
+	Stopwords bool
+	Stem      bool
+}
+
+// Chain builds the analyzer for a field with the options in the language.
+// Languages without stopwords or a stemmer skip those steps.
+func Chain(language string, options Options) *Analyzer {
+	filters := []Filter{Lowercase, FoldDiacritics, NormalizeArabic}
+	if options.Stopwords && stopwords[language] != nil {
+		filters = append(filters, Stopwords(language))
+	}
+	if stem := Stemmer(language); options.Stem && stem != nil {
+		filters = append(filters, stem)
+	}
+	return NewAnalyzer(filters...)
+}
+
+// The light stemmers below remove plural and gender endings, which is what
+// matters most for matching titles and summaries. Words are folded first,
+// so they do not need to handle accents.
+
+// spanishStem removes the plural and then the gender ending, so "libros",
+// "libro" and "libra" all become "libr"
+func spanishStem(word string) string {
+	if len(word) < 5 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ces"):
+		word = word[:len(word)-3] + "z"
+	case strings.HasSuffix(word, "os"), strings.HasSuffix(word, "as"), strings.HasSuffix(word, "es"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+	if len(word) >= 4 && strings.ContainsAny(word[len(word)-1:], "aoe") {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+// frenchStem removes the plural and feminine endings, so "petites" and
+// "petit" become "petit"
+func frenchStem(word string) string {
+	if len(word) < 5 {
+		return word
+	}
+	if strings.HasSuffix(word, "aux") {
+		return word[:len(word)-3] + "al"
+	}
+	if strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x") {
+		word = word[:len(word)-1]
+	}
+	if strings.HasSuffix(word, "e") && len(word) > 4 {
+		word = word[:len(word)-1]
+	}
+	if n := len(word); n > 4 && word[n-1] == word[n-2] && !strings.ContainsAny(word[n-1:], "aeiouy") {
+		word = word[:n-1]
+	}
+	return word
+}
+
+// germanStem removes the common plural and case endings, so "Kindern" and
+// "Kinder" become "kind"
+func germanStem(word string) string {
+	for _, suffix := range []string{"ern", "em", "en", "er", "es"} {
+		if len(word) > len(suffix)+3 && strings.HasSuffix(word, suffix) {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+	if len(word) > 4 {
+		switch word[len(word)-1] {
+		case 'e', 'n':
+			return word[:len(word)-1]
+		case 's':
+			// Only after letters that can end a German stem
+			if strings.ContainsAny(word[len(word)-2:len(word)-1], "bdfghklmnrt") {
+				return word[:len(word)-1]
+			}
+		}
+	}
+	return word
+}
@@ -0,0 +1,192 @@
+package analysis
+
+import "strings"
+
+// porter stems an English word with the Porter algorithm. Words that are not
+// plain ASCII letters are left alone.
+func porter(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	w := porterWord(word)
+	w.step1ab()
+	w.step1c()
+	w.replace(porterStep2)
+	w.replace(porterStep3)
+	w.step4()
+	w.step5()
+	return string(w)
+}
+
+type porterWord []byte
+
+// consonant reports whether the letter at i is a consonant. Y is a
+// consonant at the start of the word and after a vowel.
+func (w porterWord) consonant(i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !w.consonant(i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w[:n]
+func (w porterWord) measure(n int) int {
+	m := 0
+	vowel := false
+	for i := 0; i < n; i++ {
+		if !w.consonant(i) {
+			vowel = true
+		} else if vowel {
+			m++
+			vowel = false
+		}
+	}
+	return m
+}
+
+// hasVowel reports whether w[:n] contains a vowel
+func (w porterWord) hasVowel(n int) bool {
+	for i := 0; i < n; i++ {
+		if !w.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant reports whether w[:n] ends with a double consonant
+func (w porterWord) doubleConsonant(n int) bool {
+	return n >= 2 && w[n-1] == w[n-2] && w.consonant(n-1)
+}
+
+// cvc reports whether w[:n] ends consonant-vowel-consonant, the last not
+// being w, x or y, as in "hop" but not "snow"
+func (w porterWord) cvc(n int) bool {
+	if n < 3 || !w.consonant(n-1) || w.consonant(n-2) || !w.consonant(n-3) {
+		return false
+	}
+	c := w[n-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
+
+func (w porterWord) endsWith(suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+func (w *porterWord) setEnd(suffix, replacement string) {
+	*w = append((*w)[:len(*w)-len(suffix)], replacement...)
+}
+
+func (w *porterWord) step1ab() {
+	switch {
+	case w.endsWith("sses"), w.endsWith("ies"):
+		w.setEnd("es", "")
+	case w.endsWith("ss"):
+	case w.endsWith("s"):
+		w.setEnd("s", "")
+	}
+
+	if w.endsWith("eed") {
+		if w.measure(len(*w)-3) > 0 {
+			w.setEnd("eed", "ee")
+		}
+		return
+	}
+	var stripped bool
+	for _, suffix := range []string{"ed", "ing"} {
+		if w.endsWith(suffix) && w.hasVowel(len(*w)-len(suffix)) {
+			w.setEnd(suffix, "")
+			stripped = true
+			break
+		}
+	}
+	if !stripped {
+		return
+	}
+
+	n := len(*w)
+	switch {
+	case w.endsWith("at"), w.endsWith("bl"), w.endsWith("iz"):
+		*w = append(*w, 'e')
+	case w.doubleConsonant(n):
+		if c := (*w)[n-1]; c != 'l' && c != 's' && c != 'z' {
+			*w = (*w)[:n-1]
+		}
+	case w.measure(n) == 1 && w.cvc(n):
+		*w = append(*w, 'e')
+	}
+}
+
+func (w *porterWord) step1c() {
+	if n := len(*w); w.endsWith("y") && w.hasVowel(n-1) {
+		(*w)[n-1] = 'i'
+	}
+}
+
+var porterStep2 = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"},
+	{"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"},
+	{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"},
+	{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}, {"logi", "log"},
+}
+
+var porterStep3 = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// replace applies the first rule whose suffix ends the word, if the stem
+// before it has a vowel-consonant sequence
+func (w *porterWord) replace(rules [][2]string) {
+	for _, rule := range rules {
+		if w.endsWith(rule[0]) {
+			if w.measure(len(*w)-len(rule[0])) > 0 {
+				w.setEnd(rule[0], rule[1])
+			}
+			return
+		}
+	}
+}
+
+var porterStep4 = []string{
+	"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ion", "ism",
+	"ate", "iti", "ous", "ive", "ize", "al", "er", "ic", "ou",
+}
+
+func (w *porterWord) step4() {
+	for _, suffix := range porterStep4 {
+		if !w.endsWith(suffix) {
+			continue
+		}
+		n := len(*w) - len(suffix)
+		if suffix == "ion" && (n == 0 || ((*w)[n-1] != 's' && (*w)[n-1] != 't')) {
+			return
+		}
+		if w.measure(n) > 1 {
+			*w = (*w)[:n]
+		}
+		return
+	}
+}
+
+func (w *porterWord) step5() {
+	if w.endsWith("e") {
+		n := len(*w) - 1
+		if m := w.measure(n); m > 1 || m == 1 && !w.cvc(n) {
+			*w = (*w)[:n]
+		}
+	}
+	if n := len(*w); w.endsWith("ll") && w.measure(n) > 1 {
+		*w = (*w)[:n-1]
+	}
+}
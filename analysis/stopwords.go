@@ -0,0 +1,35 @@
+package analysis
+
+import "strings"
+
+// stopwords are the most common words of each language, after folding
+var stopwords = map[string]map[string]bool{
+	English: wordSet(`a an and are as at be but by for from has have he her his if in into is it its
+		no not of on or she such that the their then there these they this to was were will with`),
+	Spanish: wordSet(`a al como con de del el en es esta este la las lo los mas no o para pero por
+		que se sin sobre su sus un una uno y ya`),
+	French: wordSet(`a au aux ce ces dans de des du elle en est et il ils la le les leur mais ne
+		nous ou par pas pour qui que sa se ses son sur un une vous`),
+	German: wordSet(`als am an auch auf aus bei das dass dem den der des die ein eine einen einem
+		er es fur hat im in ist mit nach nicht oder sich sie so um und von war wie zu`),
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Stopwords returns a filter removing the stopwords of the language. Terms
+// must be lowercased and folded first.
+func Stopwords(language string) Filter {
+	words := stopwords[language]
+	return func(term string) string {
+		if words[term] {
+			return ""
+		}
+		return term
+	}
+}
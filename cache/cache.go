@@ -0,0 +1,225 @@
+// Package cache is a concurrency-safe LRU cache bounded by its number of
+// entries and by their total size, where every entry expires after a time to
+// live.
+//
+// The least recently used entries are evicted first when either bound is
+// reached. Expired entries are dropped when they are looked up, or evicted
+// like any other entry once they are the least recently used. GetStale can
+// still return them for a while, so they are served while being refreshed.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache maps string keys to values of type V
+type Cache[V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	order      *list.List // of *entry[V], most recently used first
+	bytes      int64
+	stats      Stats
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+}
+
+// entry is a cached value with its size and expiry
+type entry[V any] struct {
+	key     string
+	value   V
+	size    int64
+	expires time.Time
+}
+
+// Stats reports the contents of a cache and counts its lookups since it was
+// created
+type Stats struct {
+	Entries    int   `json:"entries"`
+	Bytes      int64 `json:"bytes"`
+	MaxEntries int   `json:"max_entries"`
+	MaxBytes   int64 `json:"max_bytes"`
+	// Hits and Misses count the lookups; a lookup of an expired entry is a miss
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// StaleHits counts the expired entries returned by GetStale
+	StaleHits uint64 `json:"stale_hits"`
+	// Evictions counts the entries dropped to make room for new ones
+	Evictions uint64 `json:"evictions"`
+	// Expirations counts the expired entries dropped on lookup
+	Expirations uint64 `json:"expirations"`
+}
+
+// New creates a cache holding at most maxEntries entries of at most maxBytes
+// in total, which expire after ttl by default
+func New[V any](maxEntries int, maxBytes int64, ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value cached for key, if it has not expired, and marks it
+// as the most recently used
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+	e := element.Value.(*entry[V])
+	if !c.now().Before(e.expires) {
+		c.remove(element)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+	return e.value, true
+}
+
+// GetStale returns the value cached for key like Get, and also returns it
+// when it expired less than window ago, with fresh set to false
+func (c *Cache[V]) GetStale(key string, window time.Duration) (value V, fresh bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.entries[key]
+	if !found {
+		c.stats.Misses++
+		return value, false, false
+	}
+	e := element.Value.(*entry[V])
+	now := c.now()
+	if !now.Before(e.expires.Add(window)) {
+		c.remove(element)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return value, false, false
+	}
+	c.order.MoveToFront(element)
+	if now.Before(e.expires) {
+		c.stats.Hits++
+		return e.value, true, true
+	}
+	c.stats.StaleHits++
+	return e.value, false, true
+}
+
+// Set caches the value for key with the default time to live. size is the
+// approximate memory used by the value, in bytes.
+func (c *Cache[V]) Set(key string, value V, size int64) {
+	c.SetTTL(key, value, size, c.ttl)
+}
+
+// SetTTL caches the value for key until ttl has passed, evicting the least
+// recently used entries to make room. A value larger than the whole cache is
+// not cached.
+func (c *Cache[V]) SetTTL(key string, value V, size int64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, size, ttl)
+}
+
+// SetTTLIf caches the value like SetTTL when ok returns true, and reports
+// whether it did. ok is called with the cache locked, so a DeleteFunc that
+// follows a change ok depends on always sees the value it let through.
+func (c *Cache[V]) SetTTLIf(key string, value V, size int64, ttl time.Duration, ok func() bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !ok() {
+		return false
+	}
+	c.set(key, value, size, ttl)
+	return true
+}
+
+// set caches the value; the caller holds c.mu
+func (c *Cache[V]) set(key string, value V, size int64, ttl time.Duration) {
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	if size > c.maxBytes || c.maxEntries <= 0 || ttl <= 0 {
+		return
+	}
+
+	for c.order.Len() >= c.maxEntries || c.bytes+size > c.maxBytes {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, size: size, expires: c.now().Add(ttl)})
+	c.bytes += size
+}
+
+// Delete removes the entry of key, if any
+func (c *Cache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// DeleteFunc removes every entry for which match returns true and returns
+// how many were removed
+func (c *Cache[V]) DeleteFunc(match func(key string, value V) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if e := element.Value.(*entry[V]); match(e.key, e.value) {
+			c.remove(element)
+			removed++
+		}
+		element = next
+	}
+	return removed
+}
+
+// Clear removes every entry
+func (c *Cache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+// Stats returns the current size and counters of the cache
+func (c *Cache[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Bytes = c.bytes
+	stats.MaxEntries = c.maxEntries
+	stats.MaxBytes = c.maxBytes
+	return stats
+}
+
+// remove drops an entry; the caller holds c.mu
+func (c *Cache[V]) remove(element *list.Element) {
+	e := c.order.Remove(element).(*entry[V])
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int](2, 100, time.Minute)
+	c.Set("a", 1, 10)
+	c.Set("b", 2, 10)
+	c.Get("a")
+	c.Set("c", 3, 10)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("b was kept, want it evicted as the least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheBoundsBytes(t *testing.T) {
+	c := New[string](10, 25, time.Minute)
+	c.Set("a", "a", 10)
+	c.Set("b", "b", 10)
+	c.Set("c", "c", 10)
+	if stats := c.Stats(); stats.Entries != 2 || stats.Bytes != 20 {
+		t.Errorf("Stats() = %+v, want 2 entries of 20 bytes", stats)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("a was kept, want it evicted to stay under 25 bytes")
+	}
+
+	c.Set("huge", "huge", 26)
+	if _, ok := c.Get("huge"); ok {
+		t.Errorf("an entry larger than the cache was cached")
+	}
+
+	c.Set("b", "b", 5)
+	if stats := c.Stats(); stats.Bytes != 15 {
+		t.Errorf("replacing b left %d bytes, want 15", stats.Bytes)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	now := time.Now()
+	c := New[int](10, 100, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Set("a", 1, 1)
+	c.SetTTL("b", 2, 1, time.Hour)
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("a was returned after its time to live")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("b expired before its own time to live")
+	}
+	if stats := c.Stats(); stats.Entries != 1 || stats.Expirations != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheDeleteFunc(t *testing.T) {
+	c := New[int](10, 100, time.Minute)
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprint(i), i, 1)
+	}
+	if n := c.DeleteFunc(func(key string, value int) bool { return value%2 == 0 }); n != 3 {
+		t.Errorf("DeleteFunc removed %d entries, want 3", n)
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Bytes != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheConcurrentUse(t *testing.T) {
+	c := New[int](50, 1000, time.Minute)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint((g * i) % 100)
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, i, 10)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Entries > 50 || stats.Bytes > 1000 || stats.Hits+stats.Misses != 8000 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheGetStale(t *testing.T) {
+	now := time.Now()
+	c := New[int](10, 100, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Set("a", 1, 1)
+
+	if value, fresh, ok := c.GetStale("a", time.Minute); value != 1 || !fresh || !ok {
+		t.Errorf("GetStale before the TTL = %d, %v, %v, want 1, fresh", value, fresh, ok)
+	}
+	now = now.Add(90 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get returned an expired entry")
+	}
+
+	c.Set("a", 1, 1)
+	now = now.Add(90 * time.Second)
+	if value, fresh, ok := c.GetStale("a", time.Minute); value != 1 || fresh || !ok {
+		t.Errorf("GetStale within the window = %d, %v, %v, want 1, stale", value, fresh, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, _, ok := c.GetStale("a", time.Minute); ok {
+		t.Errorf("GetStale returned an entry expired for longer than the window")
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.StaleHits != 1 || stats.Misses != 2 || stats.Expirations != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheSetTTLIf(t *testing.T) {
+	c := New[uint64](1000, 1000, time.Minute)
+	if c.SetTTLIf("a", 1, 1, time.Minute, func() bool { return false }) {
+		t.Fatalf("SetTTLIf cached a value it was told not to")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("Get returned a value SetTTLIf did not cache")
+	}
+
+	// A writer stores values of the current version while it is unchanged, and
+	// an invalidation bumps the version and then drops every older value. No
+	// older value may survive the last invalidation.
+	var version atomic.Uint64
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				v := version.Load()
+				c.SetTTLIf(fmt.Sprint(w, i%20), v, 1, time.Minute, func() bool { return version.Load() == v })
+			}
+		}(w)
+	}
+	for i := 0; i < 100; i++ {
+		v := version.Add(1)
+		c.DeleteFunc(func(key string, value uint64) bool { return value < v })
+	}
+	wg.Wait()
+
+	last := version.Load()
+	c.DeleteFunc(func(key string, value uint64) bool {
+		if value < last {
+			t.Errorf("%s holds version %d after the invalidation of version %d", key, value, last)
+		}
+		return false
+	})
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// errPanicked is returned to the callers waiting on a call that panicked
+var errPanicked = errors.New("cache: the shared call panicked")
+
+// Group deduplicates concurrent calls with the same key: only the first
+// caller runs the function, and the others wait for it and share its result.
+// The zero value is ready to use.
+type Group[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+// call is a function call in flight or completed
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which
+// case it waits for that call. shared reports whether the result came from
+// another caller's call.
+func (g *Group[V]) Do(key string, fn func() (V, error)) (value V, err error, shared bool) {
+	c, started := g.start(key)
+	if !started {
+		<-c.done
+		return c.value, c.err, true
+	}
+	g.run(key, c, fn)
+	return c.value, c.err, false
+}
+
+// Go runs fn for key in the background, unless a call for key is already in
+// flight. It does not wait for the result. A panic in fn is logged rather
+// than crashing the program, and the callers waiting on it get errPanicked.
+func (g *Group[V]) Go(key string, fn func() (V, error)) {
+	if c, started := g.start(key); started {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("cache: background call for %q panicked: %v\n%s", key, r, debug.Stack())
+				}
+			}()
+			g.run(key, c, fn)
+		}()
+	}
+}
+
+// start returns the call in flight for key, or registers a new one and
+// reports that the caller must run it
+func (g *Group[V]) start(key string) (*call[V], bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok := g.calls[key]; ok {
+		return c, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*call[V])
+	}
+	c := &call[V]{done: make(chan struct{}), err: errPanicked}
+	g.calls[key] = c
+	return c, true
+}
+
+// run calls fn and releases the callers waiting on c, even if fn panics
+func (g *Group[V]) run(key string, c *call[V], fn func() (V, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn()
+}
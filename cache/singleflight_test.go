@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupDo(t *testing.T) {
+	var g Group[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err, shared := g.Do("key", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if value != 42 || err != nil {
+				t.Errorf("Do() = %d, %v", value, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+
+	// Let every caller join the call before it returns
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || sharedCount.Load() != 9 {
+		t.Errorf("fn ran %d times and %d callers shared its result, want 1 and 9", calls.Load(), sharedCount.Load())
+	}
+
+	// A later call runs again
+	if value, _, shared := g.Do("key", func() (int, error) { return 7, nil }); value != 7 || shared {
+		t.Errorf("Do() after the first call = %d, shared %v", value, shared)
+	}
+}
+
+func TestGroupGo(t *testing.T) {
+	var g Group[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 1, nil
+	}
+
+	g.Go("key", fn)
+	g.Go("key", fn)
+	done := make(chan int)
+	go func() {
+		value, _, _ := g.Do("key", fn)
+		done <- value
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if value := <-done; value != 1 || calls.Load() != 1 {
+		t.Errorf("Do() joined with %d after %d calls, want 1 after 1", value, calls.Load())
+	}
+}
+
+func TestGroupGoPanic(t *testing.T) {
+	var g Group[int]
+	release := make(chan struct{})
+	g.Go("key", func() (int, error) {
+		<-release
+		panic("refresh failed")
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err, _ := g.Do("key", func() (int, error) { return 1, nil })
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if err := <-done; err != errPanicked {
+		t.Errorf("Do() joined a panicking background call and got %v, want errPanicked", err)
+	}
+	if value, err, _ := g.Do("key", func() (int, error) { return 2, nil }); value != 2 || err != nil {
+		t.Errorf("Do() after the panic = %d, %v, want 2", value, err)
+	}
+}
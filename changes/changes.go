@@ -0,0 +1,46 @@
+// Package changes notifies the parts of the server that derive state from
+// the books and movies tables, such as the search index and the search
+// cache, that the tables changed. The importer publishes a change after
+// every import, and so must any other code that writes to the tables.
+package changes
+
+import "sync"
+
+// Change describes a change to the catalog
+type Change struct {
+	// Type is the content type that changed, "book" or "movie", or "" when
+	// any may have
+	Type string
+	// Source is what changed the catalog, such as "import"
+	Source string
+}
+
+// Full reports whether the change may affect every content type
+func (c Change) Full() bool {
+	return c.Type == ""
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers []func(Change)
+)
+
+// Subscribe calls fn with every change published from now on
+func Subscribe(fn func(Change)) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Publish calls the subscribers with the change, one after the other in the
+// order they subscribed, and returns once they all have. Derived state can
+// thus be rebuilt before the state derived from it is invalidated.
+func Publish(change Change) {
+	mu.RLock()
+	fns := subscribers
+	mu.RUnlock()
+
+	for _, fn := range fns {
+		fn(change)
+	}
+}
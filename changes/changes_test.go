@@ -0,0 +1,24 @@
+package changes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPublishInOrder(t *testing.T) {
+	saved := subscribers
+	defer func() { subscribers = saved }()
+	subscribers = nil
+
+	var calls []string
+	Subscribe(func(c Change) { calls = append(calls, "index "+c.Type) })
+	Subscribe(func(c Change) { calls = append(calls, "cache "+c.Type) })
+	Publish(Change{Type: "book", Source: "import"})
+
+	if want := []string{"index book", "cache book"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("subscribers were called as %v, want %v", calls, want)
+	}
+	if (Change{Type: "movie"}).Full() || !(Change{}).Full() {
+		t.Errorf("Full() should only be true without a type")
+	}
+}
@@ -0,0 +1,72 @@
+// Package editdistance computes Unicode-aware Damerau–Levenshtein distances.
+//
+// Strings are compared rune by rune, so accented Latin letters and Arabic
+// characters count as a single edit. Transpositions of two adjacent runes
+// count as one edit (the optimal string alignment variant), and only three
+// rolling rows are kept instead of the full matrix.
+package editdistance
+
+// Distance returns the Damerau–Levenshtein distance between a and b
+func Distance(a, b string) int {
+	d, _ := distance([]rune(a), []rune(b), -1)
+	return d
+}
+
+// Within returns the distance between a and b and whether it is at most max.
+// It stops as soon as the distance is known to exceed max, in which case the
+// returned distance is max+1.
+func Within(a, b string, max int) (int, bool) {
+	return distance([]rune(a), []rune(b), max)
+}
+
+// distance computes the distance between a and b, giving up once it exceeds
+// max when max is not negative
+func distance(a, b []rune, max int) (int, bool) {
+	// Keep the shorter string in the rows
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if max >= 0 && len(a)-len(b) > max {
+		return max + 1, false
+	}
+	if len(b) == 0 {
+		return len(a), max < 0 || len(a) <= max
+	}
+
+	// prev2, prev and cur are rows i-2, i-1 and i of the distance matrix
+	rows := make([]int, 3*(len(b)+1))
+	prev2, prev, cur := rows[:len(b)+1], rows[len(b)+1:2*(len(b)+1)], rows[2*(len(b)+1):]
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+
+			// Adjacent transposition
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+
+		// Every later row is at least as large as the smallest value of this one
+		if max >= 0 && rowMin > max {
+			return max + 1, false
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	d := prev[len(b)]
+	if max >= 0 && d > max {
+		return max + 1, false
+	}
+	return d, true
+}
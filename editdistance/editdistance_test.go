@@ -0,0 +1,102 @@
+package editdistance
+
+import (
+	"encoding/csv"
+	"os"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"harry", "hary", 1},
+		{"tolkien", "tolkein", 1},
+		{"ca", "abc", 3},
+		{"GrandPré", "GrandPre", 1},
+		{"Pré", "Pre", 1},
+		{"حبيبي", "حبيبى", 1},
+		{"كتاب", "كتاب", 0},
+	}
+
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		max    int
+		want   int
+		within bool
+	}{
+		{"harry", "hary", 1, 1, true},
+		{"harry", "hary", 0, 1, false},
+		{"kitten", "sitting", 2, 3, false},
+		{"kitten", "sitting", 3, 3, true},
+		{"potter", "completely different", 2, 3, false},
+		{"", "ab", 1, 2, false},
+	}
+
+	for _, tt := range tests {
+		got, within := Within(tt.a, tt.b, tt.max)
+		if got != tt.want || within != tt.within {
+			t.Errorf("Within(%q, %q, %d) = %d, %v, want %d, %v", tt.a, tt.b, tt.max, got, within, tt.want, tt.within)
+		}
+	}
+}
+
+// loadTitles reads the book titles from books.csv
+func loadTitles(b *testing.B) []string {
+	file, err := os.Open("../books.csv")
+	if err != nil {
+		b.Skipf("books.csv not available: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		b.Fatalf("error reading books.csv: %v", err)
+	}
+
+	var titles []string
+	for _, record := range records[1:] {
+		if len(record) > 1 {
+			titles = append(titles, record[1])
+		}
+	}
+	return titles
+}
+
+func BenchmarkDistance(b *testing.B) {
+	titles := loadTitles(b)
+	query := "Harry Potter and the Half-Blood Prince"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Distance(query, titles[i%len(titles)])
+	}
+}
+
+func BenchmarkWithin(b *testing.B) {
+	titles := loadTitles(b)
+	query := "Harry Potter and the Half-Blood Prince"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Within(query, titles[i%len(titles)], 2)
+	}
+}
@@ -0,0 +1,64 @@
+package endpoints
+
+import (
+	"anghami-exercise/search"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// defaultAutocompleteLimit is the number of completions returned when no limit is given
+const defaultAutocompleteLimit = 10
+
+type Completion struct {
+	ID     int     `json:"id"`
+	Title  string  `json:"title"`
+	Type   string  `json:"type"`
+	Rating float64 `json:"rating"`
+}
+
+type AutocompleteResponse struct {
+	Query       string       `json:"query"`
+	Completions []Completion `json:"completions"`
+}
+
+// AutocompleteHandler handles the /autocomplete endpoint
+func AutocompleteHandler(catalog *search.Catalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query().Get("q")
+
+		limit := defaultAutocompleteLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > search.MaxCompletions {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", search.MaxCompletions), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		// Look the prefix up in the completion trie
+		completions := []Completion{}
+		for _, doc := range catalog.Autocompleter().Complete(query, limit) {
+			completions = append(completions, Completion{
+				ID:     doc.ID,
+				Title:  doc.Title,
+				Type:   doc.Type,
+				Rating: doc.Rating,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(AutocompleteResponse{Query: query, Completions: completions})
+		if err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
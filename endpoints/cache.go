@@ -0,0 +1,152 @@
+package endpoints
+
+import (
+	"anghami-exercise/cache"
+	"anghami-exercise/changes"
+	"anghami-exercise/search"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// The default bounds of the search cache
+const (
+	DefaultCacheEntries = 10000
+	DefaultCacheBytes   = 64 << 20
+)
+
+// cacheExpiration is how long search results are cached, and
+// negativeCacheExpiration how long searches without results are. Searches
+// without results expire sooner, as documents that match them may be added.
+var (
+	cacheExpiration         = 30 * time.Second
+	negativeCacheExpiration = 5 * time.Second
+)
+
+// staleWindow is how long after they expire cached results are still served
+// while they are refreshed
+var staleWindow = 5 * time.Minute
+
+// inflightSearches deduplicates the concurrent backend calls of a search
+var inflightSearches cache.Group[*CacheEntry]
+
+// SearchCache holds the ranked results of recent searches, by searchKey
+var SearchCache = cache.New[*CacheEntry](DefaultCacheEntries, DefaultCacheBytes, cacheExpiration)
+
+// ConfigureSearchCache replaces the search cache with an empty one holding
+// at most maxEntries searches of at most maxBytes in total
+func ConfigureSearchCache(maxEntries int, maxBytes int64) {
+	SearchCache = cache.New[*CacheEntry](maxEntries, maxBytes, cacheExpiration)
+}
+
+// searchKey identifies the cached results of a search. The query is keyed in
+// the canonical form of its parsed expression, so "Harry Potter",
+// " harry  potter" and "HARRY POTTER" share an entry. The other parameters
+// that change the results, the filters, are keyed by a hash of their
+// canonical form. Sorting, pagination, facets and snippets are applied to
+// the cached results, so they are not part of the key.
+func searchKey(expr *search.Expr, filters *search.Filter) string {
+	key := expr.String()
+	if hash := filtersHash(filters); hash != "" {
+		key += "\x00" + hash
+	}
+	return key
+}
+
+// filtersHash returns a stable hash of the canonical form of the filters, or
+// "" when they let every document through
+func filtersHash(filters *search.Filter) string {
+	canonical := filters.Canonical()
+	if canonical == nil {
+		return ""
+	}
+	encoded, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:16])
+}
+
+// catalogVersion counts the changes to the catalog, so clients can tell
+// which version of the catalog results reflect
+var catalogVersion atomic.Uint64
+
+// InvalidateCatalog drops the cached searches a catalog change may affect
+// and bumps the catalog version. A change to one content type keeps the
+// searches filtered to the other type.
+func InvalidateCatalog(change changes.Change) {
+	catalogVersion.Add(1)
+	removed := SearchCache.DeleteFunc(func(key string, entry *CacheEntry) bool {
+		return change.Full() || entry.Type == "" || entry.Type == change.Type
+	})
+	log.Printf("Catalog changed by %s, now at version %d: %d cached searches dropped", change.Source, catalogVersion.Load(), removed)
+}
+
+// CacheEntry is the ranked results of a search with where and when they
+// were computed
+type CacheEntry struct {
+	Results   []SearchResult
+	CreatedAt time.Time
+	TTL       time.Duration
+	// Backend is the name of the search backend that found the results
+	Backend string
+	Count   int
+	// CatalogVersion is the version of the catalog that was searched
+	CatalogVersion uint64
+	// Type is the content type the search is filtered to, "" for both
+	Type string
+}
+
+// newCacheEntry wraps the results of a search by the backend, with the
+// shorter negative TTL when there are none
+func newCacheEntry(backend string, results []SearchResult, version uint64, docType string) *CacheEntry {
+	ttl := cacheExpiration
+	if len(results) == 0 {
+		ttl = negativeCacheExpiration
+	}
+	return &CacheEntry{
+		Results:        results,
+		CreatedAt:      time.Now(),
+		TTL:            ttl,
+		Backend:        backend,
+		Count:          len(results),
+		CatalogVersion: version,
+		Type:           docType,
+	}
+}
+
+// size estimates the memory used by the entry cached under key. The
+// documents and matchers the results point to are shared with the index and
+// are not counted.
+func (e *CacheEntry) size(key string) int64 {
+	size := int64(len(key)+len(e.Backend)) + int64(unsafe.Sizeof(*e))
+	size += int64(cap(e.Results)) * int64(unsafe.Sizeof(SearchResult{}))
+	for _, result := range e.Results {
+		size += int64(len(result.Title) + len(result.Type))
+		for name := range result.Scores {
+			// A map entry is roughly its key, its value and a pointer of overhead
+			size += int64(len(name)) + 32
+		}
+		for _, field := range result.MatchedFields {
+			size += int64(len(field)) + 16
+		}
+	}
+	return size
+}
+
+// CacheHandler handles the /admin/cache endpoint: GET returns the size of
+// the search cache and its hit, miss and eviction counters
+func CacheHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SearchCache.Stats())
+	}
+}
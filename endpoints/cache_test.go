@@ -0,0 +1,340 @@
+package endpoints
+
+import (
+	"anghami-exercise/changes"
+	"anghami-exercise/search"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// countingBackend counts the searches that reach the backend, which take at
+// least delay
+type countingBackend struct {
+	*search.MemoryBackend
+	searches atomic.Int32
+	delay    time.Duration
+}
+
+func (b *countingBackend) Search(query search.Query) ([]search.Hit, error) {
+	b.searches.Add(1)
+	time.Sleep(b.delay)
+	return b.MemoryBackend.Search(query)
+}
+
+func newCountingBackend() *countingBackend {
+	b := &countingBackend{MemoryBackend: search.NewMemoryBackend()}
+	b.Index([]*search.Document{
+		{ID: 1, Type: "book", Title: "Harry Potter and the Sorcerer's Stone", Rating: 4.4, Year: 1998},
+		{ID: 2, Type: "movie", Title: "When Harry Met Sally", Rating: 7.6, Year: 1989},
+	})
+	return b
+}
+
+// withCacheTTLs runs the test with an empty cache, the given TTLs and the
+// given window for serving expired entries
+func withCacheTTLs(t *testing.T, ttl, negativeTTL, stale time.Duration) {
+	cache, savedTTL, savedNegativeTTL, savedStale := SearchCache, cacheExpiration, negativeCacheExpiration, staleWindow
+	cacheExpiration, negativeCacheExpiration, staleWindow = ttl, negativeTTL, stale
+	ConfigureSearchCache(DefaultCacheEntries, DefaultCacheBytes)
+	t.Cleanup(func() {
+		SearchCache, cacheExpiration, negativeCacheExpiration, staleWindow = cache, savedTTL, savedNegativeTTL, savedStale
+	})
+}
+
+func cachedQuery(t *testing.T, backend search.SearchBackend, query string) ([]SearchResult, bool) {
+	t.Helper()
+	expr, err := search.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, cached, err := cachedSearch(backend, search.NewCatalog(backend), query, expr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry.Results, cached
+}
+
+func TestCachedSearchExpires(t *testing.T) {
+	withCacheTTLs(t, 50*time.Millisecond, time.Hour, 0)
+	backend := newCountingBackend()
+
+	if results, cached := cachedQuery(t, backend, "harry"); len(results) != 2 || cached {
+		t.Fatalf("first search returned %d results, cached %v", len(results), cached)
+	}
+	if results, cached := cachedQuery(t, backend, "harry"); len(results) != 2 || !cached {
+		t.Fatalf("second search returned %d results, cached %v, want them from the cache", len(results), cached)
+	}
+
+	entry, ok := SearchCache.Get("harry")
+	if !ok {
+		t.Fatal("the search is not cached")
+	}
+	if entry.Count != 2 || entry.Backend != "memory" || entry.TTL != cacheExpiration || entry.CreatedAt.IsZero() {
+		t.Errorf("cache entry = %+v", entry)
+	}
+	for _, result := range entry.Results {
+		if !result.Timestamp.Equal(entry.Results[0].Timestamp) || result.Timestamp.After(entry.CreatedAt) {
+			t.Errorf("result timestamp %v, want when the results were computed", result.Timestamp)
+		}
+	}
+
+	time.Sleep(2 * cacheExpiration)
+	if _, cached := cachedQuery(t, backend, "harry"); cached {
+		t.Error("search was served from the cache after its TTL")
+	}
+	if n := backend.searches.Load(); n != 2 {
+		t.Errorf("backend searched %d times, want 2", n)
+	}
+}
+
+func TestCachedSearchEmptyResults(t *testing.T) {
+	withCacheTTLs(t, time.Hour, 50*time.Millisecond, 0)
+	backend := newCountingBackend()
+
+	cachedQuery(t, backend, "harry")
+	if results, cached := cachedQuery(t, backend, "zzzz"); len(results) != 0 || cached {
+		t.Fatalf("first search returned %d results, cached %v", len(results), cached)
+	}
+	if results, cached := cachedQuery(t, backend, "zzzz"); len(results) != 0 || !cached {
+		t.Fatalf("second search returned %d results, cached %v, want the empty results from the cache", len(results), cached)
+	}
+	if entry, ok := SearchCache.Get("zzzz"); !ok || entry.Count != 0 || entry.TTL != negativeCacheExpiration {
+		t.Errorf("cache entry = %+v, want no results with the negative TTL", entry)
+	}
+
+	time.Sleep(2 * negativeCacheExpiration)
+	if _, cached := cachedQuery(t, backend, "zzzz"); cached {
+		t.Error("search without results was served from the cache after the negative TTL")
+	}
+	if _, cached := cachedQuery(t, backend, "harry"); !cached {
+		t.Error("search with results expired with the negative TTL")
+	}
+	if n := backend.searches.Load(); n != 3 {
+		t.Errorf("backend searched %d times, want 3", n)
+	}
+}
+
+func TestCachedSearchCoalescesMisses(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend := newCountingBackend()
+	backend.delay = 50 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if results, _ := cachedQuery(t, backend, "harry"); len(results) != 2 {
+				t.Errorf("search returned %d results, want 2", len(results))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := backend.searches.Load(); n != 1 {
+		t.Errorf("backend searched %d times for concurrent identical searches, want 1", n)
+	}
+}
+
+func TestCachedSearchServesStale(t *testing.T) {
+	withCacheTTLs(t, 50*time.Millisecond, 50*time.Millisecond, time.Hour)
+	backend := newCountingBackend()
+
+	cachedQuery(t, backend, "harry")
+	first, _, _ := SearchCache.GetStale("harry", staleWindow)
+	time.Sleep(2 * cacheExpiration)
+
+	if results, cached := cachedQuery(t, backend, "harry"); len(results) != 2 || !cached {
+		t.Fatalf("expired search returned %d results, cached %v, want the stale results", len(results), cached)
+	}
+
+	// The stale entry is refreshed in the background
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(5 * time.Millisecond) {
+		if entry, fresh, _ := SearchCache.GetStale("harry", staleWindow); fresh && entry != first {
+			break
+		}
+	}
+	if entry, fresh, _ := SearchCache.GetStale("harry", staleWindow); !fresh || entry == first {
+		t.Errorf("the stale entry was not refreshed")
+	}
+	if n := backend.searches.Load(); n != 2 {
+		t.Errorf("backend searched %d times, want 2", n)
+	}
+}
+
+// keyWords are the words the property tests build queries from
+var keyWords = []string{"harry", "Potter", "lord", "of", "the", "RINGS", "tolkien", "movies", "2017", "Pré"}
+
+// restyle writes the words with random case and spacing
+func restyle(words []string, upper []bool, spaces []uint8) string {
+	var b strings.Builder
+	for i, word := range words {
+		if i < len(upper) && upper[i] {
+			word = strings.ToUpper(word)
+		} else {
+			word = strings.ToLower(word)
+		}
+		gap := 1
+		if i < len(spaces) {
+			gap += int(spaces[i] % 3)
+		}
+		b.WriteString(strings.Repeat(" ", gap))
+		b.WriteString(word)
+	}
+	b.WriteString(" ")
+	return b.String()
+}
+
+func pickWords(picks []uint8) []string {
+	words := make([]string, len(picks))
+	for i, pick := range picks {
+		words[i] = keyWords[int(pick)%len(keyWords)]
+	}
+	return words
+}
+
+func mustParse(t *testing.T, query string) *search.Expr {
+	t.Helper()
+	expr, err := search.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q) returned error %v", query, err)
+	}
+	return expr
+}
+
+func TestSearchKeyIgnoresCaseAndSpacing(t *testing.T) {
+	property := func(picks []uint8, upper []bool, spaces []uint8) bool {
+		words := pickWords(picks)
+		query := strings.Join(words, " ")
+		variant := restyle(words, upper, spaces)
+		return searchKey(mustParse(t, query), nil) == searchKey(mustParse(t, variant), nil)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSearchKeyCanonicalFilters(t *testing.T) {
+	expr := mustParse(t, "harry")
+	property := func(movie bool, min, max int16, upperLanguage bool) bool {
+		year, yearMax := int(min), int(max)
+		filter := &search.Filter{Type: "book", Year: &search.IntRange{Min: &year, Max: &yearMax}, Language: "eng"}
+		variant := &search.Filter{Type: "book", Year: &search.IntRange{Min: &year, Max: &yearMax}, Language: "eng", Pages: &search.IntRange{}}
+		if movie {
+			filter = &search.Filter{Type: "movie", Year: &search.IntRange{Min: &year}}
+			variant = &search.Filter{Type: "movie", Year: &search.IntRange{Min: &year}, Rating: &search.FloatRange{}}
+		}
+		if upperLanguage {
+			variant.Language = strings.ToUpper(variant.Language)
+		}
+
+		// A different year range is a different search
+		other := *filter
+		otherYear := year + 1
+		other.Year = &search.IntRange{Min: &otherYear, Max: filter.Year.Max}
+
+		return searchKey(expr, filter) == searchKey(expr, variant) &&
+			searchKey(expr, filter) != searchKey(expr, &other) &&
+			searchKey(expr, filter) != searchKey(expr, nil) &&
+			searchKey(expr, &search.Filter{Year: &search.IntRange{}}) == searchKey(expr, nil)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEquivalentRequestsShareCacheEntry(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend := newCountingBackend()
+	catalog := search.NewCatalog(backend)
+	sorts := []string{"relevance", "rating:desc", "title", "date:asc"}
+
+	post := func(request map[string]interface{}) SearchResponse {
+		body, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		SearchHandler(backend, catalog)(w, httptest.NewRequest("POST", "/search", strings.NewReader(string(body))))
+		var response SearchResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		return response
+	}
+
+	property := func(picks []uint8, upper []bool, spaces []uint8, limit, offset, sort uint8, language bool) bool {
+		words := pickWords(picks)
+		if len(words) == 0 {
+			return true
+		}
+		SearchCache.Clear()
+		filters := map[string]interface{}{"type": "book"}
+		variantFilters := map[string]interface{}{"type": "book", "pages": map[string]interface{}{}}
+		if language {
+			filters["language"], variantFilters["language"] = "eng", "ENG"
+		}
+
+		first := post(map[string]interface{}{"search_query": strings.Join(words, " "), "filters": filters})
+		second := post(map[string]interface{}{
+			"search_query": restyle(words, upper, spaces),
+			"filters":      variantFilters,
+			"limit":        int(limit%20) + 1,
+			"offset":       int(offset % 5),
+			"sort":         []string{sorts[int(sort)%len(sorts)]},
+			"facets":       []string{"type"},
+			"snippet":      "html",
+		})
+		if first.Cached || !second.Cached || first.TotalHits != second.TotalHits {
+			t.Logf("%v: first cached %v, second cached %v", words, first.Cached, second.Cached)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 50}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidateCatalog(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend := newCountingBackend()
+	catalog := search.NewCatalog(backend)
+	filtered := func(docType string) {
+		t.Helper()
+		if _, _, err := cachedSearch(backend, catalog, "harry", mustParse(t, "harry"), nil, &search.Filter{Type: docType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func(docType string) bool {
+		_, ok := SearchCache.Get(searchKey(mustParse(t, "harry"), &search.Filter{Type: docType}))
+		return ok
+	}
+
+	filtered("")
+	filtered("book")
+	filtered("movie")
+	version := catalogVersion.Load()
+
+	InvalidateCatalog(changes.Change{Type: "book", Source: "test"})
+	if cached("") || cached("book") || !cached("movie") {
+		t.Errorf("a book change left all %v, books %v, movies %v cached, want only movies", cached(""), cached("book"), cached("movie"))
+	}
+	if catalogVersion.Load() != version+1 {
+		t.Errorf("catalog version is %d, want %d", catalogVersion.Load(), version+1)
+	}
+
+	// Searches now reflect the new version
+	entry, fromCache, err := cachedSearch(backend, catalog, "harry", mustParse(t, "harry"), nil, nil)
+	if err != nil || fromCache || entry.CatalogVersion != version+1 {
+		t.Errorf("search after the change = version %d, cached %v, %v", entry.CatalogVersion, fromCache, err)
+	}
+
+	InvalidateCatalog(changes.Change{Source: "test"})
+	if cached("") || cached("movie") {
+		t.Errorf("a full change left searches cached")
+	}
+}
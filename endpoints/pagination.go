@@ -0,0 +1,102 @@
+package endpoints
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	// defaultPageSize is the number of results returned when no limit is given
+	defaultPageSize = 20
+	// maxPageSize is the largest page a client can request
+	maxPageSize = 100
+)
+
+// pageCursor is the decoded form of the opaque cursor returned as next_cursor
+type pageCursor struct {
+	Offset int    `json:"o"`
+	Limit  int    `json:"l"`
+	Query  uint32 `json:"q"` // fingerprint of the search it belongs to
+}
+
+// encodeCursor returns the opaque cursor of the page starting at offset
+func encodeCursor(searchQuery string, offset, limit int) string {
+	payload, _ := json.Marshal(pageCursor{Offset: offset, Limit: limit, Query: queryFingerprint(searchQuery)})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeCursor parses a cursor and checks that it belongs to the search query
+func decodeCursor(cursor, searchQuery string) (pageCursor, error) {
+	var c pageCursor
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(payload, &c) != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if c.Query != queryFingerprint(searchQuery) {
+		return c, fmt.Errorf("cursor does not belong to this search")
+	}
+	if c.Offset < 0 || c.Limit < 1 || c.Limit > maxPageSize {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// queryFingerprint identifies the search a cursor was issued for
+func queryFingerprint(searchQuery string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(searchQuery))
+	return h.Sum32()
+}
+
+// pageKey identifies the ordered result list a cursor pages through: the
+// search and its sort
+func pageKey(request SearchRequest) string {
+	return request.SearchQuery + "\x00" + filtersHash(request.Filters) + "\x00" + strings.Join(request.Sort, ",")
+}
+
+// resolvePage validates the pagination fields of a request and returns the
+// offset and limit of the requested page
+func resolvePage(request SearchRequest) (int, int, error) {
+	if request.Cursor != "" {
+		if request.Offset != 0 {
+			return 0, 0, fmt.Errorf("offset cannot be combined with cursor")
+		}
+		c, err := decodeCursor(request.Cursor, pageKey(request))
+		if err != nil {
+			return 0, 0, err
+		}
+		if request.Limit != 0 {
+			c.Limit = request.Limit
+		}
+		request.Offset, request.Limit = c.Offset, c.Limit
+	}
+
+	if request.Limit == 0 {
+		request.Limit = defaultPageSize
+	}
+	if request.Limit < 1 || request.Limit > maxPageSize {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	if request.Offset < 0 {
+		return 0, 0, fmt.Errorf("offset cannot be negative")
+	}
+
+	return request.Offset, request.Limit, nil
+}
+
+// paginate returns one page of results and the cursor of the next page, if any
+func paginate(results []SearchResult, searchQuery string, offset, limit int) ([]SearchResult, string) {
+	if offset >= len(results) {
+		return []SearchResult{}, ""
+	}
+
+	end := min(offset+limit, len(results))
+	nextCursor := ""
+	if end < len(results) {
+		nextCursor = encodeCursor(searchQuery, end, limit)
+	}
+	return results[offset:end], nextCursor
+}
@@ -0,0 +1,123 @@
+package endpoints
+
+import (
+	"anghami-exercise/search"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	results := make([]SearchResult, 45)
+	for i := range results {
+		results[i].ID = i
+	}
+	request := SearchRequest{SearchQuery: "harry", Filters: &search.Filter{Type: "book"}, Sort: []string{"rating"}, Limit: 20}
+
+	var ids []int
+	for {
+		offset, limit, err := resolvePage(request)
+		if err != nil {
+			t.Fatalf("offset %d: %v", len(ids), err)
+		}
+		page, next := paginate(results, pageKey(request), offset, limit)
+		for _, result := range page {
+			ids = append(ids, result.ID)
+		}
+		if next == "" {
+			break
+		}
+		// Later pages keep the limit of the first
+		request.Cursor, request.Limit = next, 0
+	}
+
+	if len(ids) != len(results) {
+		t.Fatalf("paged through %d results, want %d", len(ids), len(results))
+	}
+	for i, id := range ids {
+		if id != i {
+			t.Fatalf("result %d is %d, want every result once in order", i, id)
+		}
+	}
+}
+
+func TestCursorRejected(t *testing.T) {
+	request := SearchRequest{SearchQuery: "harry", Filters: &search.Filter{Type: "book"}, Sort: []string{"rating"}}
+	cursor := encodeCursor(pageKey(request), 20, 20)
+
+	tamper := func(edit func(c *pageCursor)) string {
+		payload, _ := base64.RawURLEncoding.DecodeString(cursor)
+		var c pageCursor
+		json.Unmarshal(payload, &c)
+		edit(&c)
+		payload, _ = json.Marshal(c)
+		return base64.RawURLEncoding.EncodeToString(payload)
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(r *SearchRequest)
+		cursor string
+	}{
+		{name: "other query", edit: func(r *SearchRequest) { r.SearchQuery = "potter" }},
+		{name: "other filter", edit: func(r *SearchRequest) { r.Filters = &search.Filter{Type: "movie"} }},
+		{name: "no filter", edit: func(r *SearchRequest) { r.Filters = nil }},
+		{name: "other sort", edit: func(r *SearchRequest) { r.Sort = []string{"title"} }},
+		{name: "offset", edit: func(r *SearchRequest) { r.Offset = 20 }},
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("{20"))},
+		{name: "truncated", cursor: cursor[:len(cursor)-4]},
+		{name: "fingerprint", cursor: tamper(func(c *pageCursor) { c.Query++ })},
+		{name: "negative offset", cursor: tamper(func(c *pageCursor) { c.Offset = -20 })},
+		{name: "limit too large", cursor: tamper(func(c *pageCursor) { c.Limit = maxPageSize + 1 })},
+		{name: "no limit", cursor: tamper(func(c *pageCursor) { c.Limit = 0 })},
+	}
+	for _, test := range tests {
+		r := request
+		r.Cursor = cursor
+		if test.cursor != "" {
+			r.Cursor = test.cursor
+		}
+		if test.edit != nil {
+			test.edit(&r)
+		}
+		if offset, limit, err := resolvePage(r); err == nil {
+			t.Errorf("%s: resolvePage = %d, %d, want an error", test.name, offset, limit)
+		}
+	}
+
+	r := request
+	r.Cursor = cursor
+	if offset, limit, err := resolvePage(r); err != nil || offset != 20 || limit != 20 {
+		t.Errorf("resolvePage = %d, %d, %v, want the second page", offset, limit, err)
+	}
+}
+
+func TestPageLimits(t *testing.T) {
+	tests := []struct {
+		limit, offset int
+		wantLimit     int
+		wantErr       bool
+	}{
+		{limit: 0, wantLimit: defaultPageSize},
+		{limit: 1, wantLimit: 1},
+		{limit: maxPageSize, wantLimit: maxPageSize},
+		{limit: maxPageSize + 1, wantErr: true},
+		{limit: -1, wantErr: true},
+		{offset: -1, wantErr: true},
+	}
+	for _, test := range tests {
+		_, limit, err := resolvePage(SearchRequest{SearchQuery: "harry", Limit: test.limit, Offset: test.offset})
+		if (err != nil) != test.wantErr || (!test.wantErr && limit != test.wantLimit) {
+			t.Errorf("resolvePage(limit %d, offset %d) = %d, %v, want %d (error %v)", test.limit, test.offset, limit, err, test.wantLimit, test.wantErr)
+		}
+	}
+
+	results := make([]SearchResult, 5)
+	if page, next := paginate(results, "harry", 5, 10); len(page) != 0 || next != "" {
+		t.Errorf("paginate past the end = %d results and cursor %q, want none", len(page), next)
+	}
+	if page, next := paginate(results, "harry", 0, 5); len(page) != 5 || next != "" {
+		t.Errorf("paginate of a full last page = %d results and cursor %q, want 5 and no cursor", len(page), next)
+	}
+}
@@ -1,18 +1,26 @@
 package endpoints
 
 import (
-	"database/sql"
+	"anghami-exercise/search"
 	"encoding/json"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 )
 
 type SearchRequest struct {
     SearchQuery string `json:"search_query"`
+    Limit       int    `json:"limit"`
+    Offset      int    `json:"offset"`
+    Cursor      string `json:"cursor"`
+    Filters     *search.Filter `json:"filters"`
+    Facets      []string       `json:"facets"`
+    Sort        []string       `json:"sort"`
+    Snippet     string         `json:"snippet"`
+    AutoCorrect bool           `json:"auto_correct"`
 }
 
 type SearchResult struct {
@@ -21,20 +29,40 @@ type SearchResult struct {
     Type      string    `json:"type"`
     Rating    float64   `json:"rating"`
     Timestamp time.Time `json:"timestamp"`
-	RelevanceScore int
+    Score     float64   `json:"score"`
+    Scores    map[string]float64 `json:"scores"`
+    MatchedFields []string      `json:"matched_fields"`
+    Typos     int               `json:"typos"`
+    Highlights []search.Highlight `json:"highlights"`
+    Snippet   string            `json:"snippet,omitempty"`
+    MatchType string            `json:"match_type,omitempty"`
+
+    doc     *search.Document // the matched document, kept for facets and sorting
+    matcher *search.Matcher  // the matched query words, kept for highlighting
 }
 
 type SearchResponse struct {
-    Results  []SearchResult `json:"results"`
-    Cached   bool           `json:"is_cached"`
-    SearchID string         `json:"search_id"`
+    Results    []SearchResult `json:"results"`
+    Cached     bool           `json:"is_cached"`
+    SearchID   string         `json:"search_id"`
+    TotalHits  int            `json:"total_hits"`
+    NextCursor string         `json:"next_cursor,omitempty"`
+    Facets     map[string][]search.FacetCount `json:"facets,omitempty"`
+    Suggestion string         `json:"suggestion,omitempty"`
+    AutoCorrected bool        `json:"auto_corrected,omitempty"`
+    Interpretation *search.Interpretation `json:"interpretation,omitempty"`
+    CatalogVersion uint64     `json:"catalog_version"`
 }
 
-var SearchCache map[string][]SearchResult
-const cacheExpiration = 30 * time.Second
+// RankingPipeline orders the hits returned by the search backend
+var RankingPipeline = search.DefaultPipeline()
+
+// suggestBelowHits is the number of hits under which a "did you mean"
+// suggestion is returned
+const suggestBelowHits = 3
 
 // SearchHandler function to handle /search endpoint
-func SearchHandler(db *sql.DB) http.HandlerFunc {
+func SearchHandler(backend search.SearchBackend, catalog *search.Catalog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -43,51 +71,121 @@ func SearchHandler(db *sql.DB) http.HandlerFunc {
 
 		// Parse request body and extract search query
 		var request SearchRequest
-		err := json.NewDecoder(r.Body).Decode(&request)
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&request)
 		if err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Check if the search query exists in the cache
-		cachedResults, found := SearchCache[request.SearchQuery]
-		if found && !isExpired(cachedResults[0].Timestamp) {
-			// If search query is found in the cache and it's not expired, return cached results
-			response := SearchResponse{
-				Results:  cachedResults,
-				Cached:   true,
-				SearchID: generateSearchID(),
-			}
-			sendResponse(w, response)
+		if err := request.Filters.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		expr, err := search.ParseQuery(request.SearchQuery)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := search.ValidateFacets(request.Facets); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if request.Snippet != "" && !search.ValidSnippetFormat(request.Snippet) {
+			http.Error(w, "snippet must be \"html\" or \"markdown\"", http.StatusBadRequest)
 			return
 		}
 
-		// Perform search in the database
-		results, err := performSearch(db, request.SearchQuery)
+		sortKeys, err := parseSort(request.Sort)
 		if err != nil {
-			fmt.Println(err)
-			http.Error(w, "Error performing search", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Cache the search results
-		SearchCache[request.SearchQuery] = results
+		offset, limit, err := resolvePage(request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		 // Calculate relevance score for each search result based on Levenshtein distance
-        for i := range results {
-            results[i].RelevanceScore = LevenshteinDistance(request.SearchQuery, results[i].Title)
-        }
+		// An ISBN or IMDB ID is looked up exactly instead of searched
+		if id, ok := search.ParseIdentifier(request.SearchQuery); ok {
+			if doc := catalog.Lookup(id); doc != nil && request.Filters.Match(doc) {
+				results := []SearchResult{{
+					ID:        doc.ID,
+					Title:     doc.Title,
+					Type:      doc.Type,
+					Rating:    doc.Rating,
+					MatchType: "identifier",
+					doc:       doc,
+				}}
+				page, nextCursor := paginate(results, pageKey(request), offset, limit)
+				sendResponse(w, SearchResponse{
+					Results:    highlight(page, request.Snippet),
+					SearchID:   generateSearchID(),
+					TotalHits:  len(results),
+					NextCursor: nextCursor,
+					Facets:     facets(results, request.Facets),
+					CatalogVersion: catalogVersion.Load(),
+				})
+				return
+			}
+		}
 
-        // Sort the search results by relevance score
-        sort.Slice(results, func(i, j int) bool {
-            return results[i].RelevanceScore < results[j].RelevanceScore
-        })
+		// Intent words such as "movies" or "2017" become filters and boosts,
+		// which must still agree with the requested filters
+		interpretation := search.Understand(expr)
+		if err := interpretedFilters(interpretation, request.Filters).Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("The filters conflict with the query: %v", err), http.StatusBadRequest)
+			return
+		}
+		entry, cached, err := cachedSearch(backend, catalog, request.SearchQuery, expr, interpretation, request.Filters)
+		if err != nil {
+			log.Printf("Error searching %q: %v", request.SearchQuery, err)
+			http.Error(w, "Error performing search", http.StatusInternalServerError)
+			return
+		}
+		results := entry.Results
+
+		// Suggest a correction for queries with few results, and search it
+		// instead when asked to and the query found nothing
+		var suggestion string
+		autoCorrected := false
+		if len(results) < suggestBelowHits {
+			suggestion = catalog.Speller().Suggest(request.SearchQuery)
+		}
+		if suggestion != "" && request.AutoCorrect && len(results) == 0 {
+			if correctedExpr, err := search.ParseQuery(suggestion); err == nil {
+				// A correction whose intents conflict with the filters is only suggested
+				corrected := search.Understand(correctedExpr)
+				if interpretedFilters(corrected, request.Filters).Validate() == nil {
+					interpretation = corrected
+					entry, cached, err = cachedSearch(backend, catalog, suggestion, correctedExpr, interpretation, request.Filters)
+					if err != nil {
+						log.Printf("Error searching the correction %q of %q: %v", suggestion, request.SearchQuery, err)
+						http.Error(w, "Error performing search", http.StatusInternalServerError)
+						return
+					}
+					results, autoCorrected = entry.Results, true
+				}
+			}
+		}
 
-		// Construct response with search results
+		// Construct response with the requested page of search results
+		page, nextCursor := paginate(sortResults(results, sortKeys), pageKey(request), offset, limit)
 		response := SearchResponse{
-			Results:  results,
-			Cached:   false,
-			SearchID: generateSearchID(),
+			Results:       highlight(page, request.Snippet),
+			Cached:        cached,
+			SearchID:      generateSearchID(),
+			TotalHits:     len(results),
+			NextCursor:    nextCursor,
+			Facets:        facets(results, request.Facets),
+			Suggestion:    suggestion,
+			AutoCorrected: autoCorrected,
+			Interpretation: interpretation,
+			CatalogVersion: entry.CatalogVersion,
 		}
 
 		// Send response back to client
@@ -95,6 +193,81 @@ func SearchHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// cachedSearch returns the results of the query and filters from the cache,
+// or searches the backend and caches them. All results are cached in
+// relevance order so cached responses can serve any sort and page.
+//
+// Concurrent misses of the same search share one backend call. A recently
+// expired entry is still returned while it is refreshed in the background.
+// Results computed while the catalog changed are returned but not cached.
+func cachedSearch(backend search.SearchBackend, catalog *search.Catalog, query string, expr *search.Expr, interpretation *search.Interpretation, filters *search.Filter) (*CacheEntry, bool, error) {
+	key := searchKey(expr, filters)
+	version := catalogVersion.Load()
+	refresh := func() (*CacheEntry, error) {
+		// Perform search against the configured backend
+		results, err := performSearch(backend, catalog, search.Query{Text: query, Expr: expr, Filter: filters}, interpretation)
+		if err != nil {
+			return nil, err
+		}
+		entry := newCacheEntry(backend.Name(), results, version, resultType(interpretation, filters))
+		// InvalidateCatalog bumps the version before it clears the cache, so
+		// an entry stored while the version is unchanged is always cleared
+		SearchCache.SetTTLIf(key, entry, entry.size(key), entry.TTL, func() bool {
+			return catalogVersion.Load() == version
+		})
+		return entry, nil
+	}
+
+	// Searches started before a catalog change do not share their results
+	// with those started after it
+	flightKey := fmt.Sprintf("%s\x00%d", key, version)
+	if entry, fresh, found := SearchCache.GetStale(key, staleWindow); found {
+		if !fresh {
+			inflightSearches.Go(flightKey, func() (*CacheEntry, error) {
+				entry, err := refresh()
+				if err != nil {
+					log.Printf("Error refreshing cached search %q: %v", query, err)
+				}
+				return entry, err
+			})
+		}
+		return entry, true, nil
+	}
+
+	entry, err, _ := inflightSearches.Do(flightKey, refresh)
+	if err != nil {
+		return nil, false, err
+	}
+	return entry, false, nil
+}
+
+// resultType returns the content type the search is filtered to, with the
+// type understood from the query, or "" if it finds both
+func resultType(interpretation *search.Interpretation, filters *search.Filter) string {
+	if filters = interpretedFilters(interpretation, filters); filters == nil {
+		return ""
+	}
+	return filters.Type
+}
+
+// interpretedFilters returns the filters with those understood from the
+// query added, if any were
+func interpretedFilters(interpretation *search.Interpretation, filters *search.Filter) *search.Filter {
+	if interpretation == nil {
+		return filters
+	}
+	return interpretation.Filter(filters)
+}
+
+// InvalidateSearches drops the cached results of every search whose query
+// uses one of the phrases, after the synonyms of those phrases changed
+func InvalidateSearches(phrases []string) {
+	SearchCache.DeleteFunc(func(key string, entry *CacheEntry) bool {
+		query, _, _ := strings.Cut(key, "\x00")
+		return search.MatchesPhrase(query, phrases)
+	})
+}
+
 // sendResponse sends the response back to the client
 func sendResponse(w http.ResponseWriter, response SearchResponse) {
 	w.Header().Set("Content-Type", "application/json")
@@ -106,97 +279,74 @@ func sendResponse(w http.ResponseWriter, response SearchResponse) {
 }
 
 
-// performSearch executes a SQL query to perform the search operation in the database
-func performSearch(db *sql.DB, searchQuery string) ([]SearchResult, error) {
-	// Prepare SQL query to search for books and movies based on the search query
-	query := `
-		SELECT bookID, title, average_rating, "book" AS type
-		FROM books
-		WHERE title LIKE ?
-		UNION ALL
-		SELECT movieID, title, rating, "movie" AS type
-		FROM movies
-		WHERE title LIKE ?
-	`
-
-	// Execute the SQL query with placeholders for search query
-	rows, err := db.Query(query, "%"+searchQuery+"%", "%"+searchQuery+"%")
-	if err != nil {
-		return nil, err
+// performSearch runs the search query against the backend and ranks the hits
+// with the ranking pipeline. An interpreted query is searched without its
+// intent words, with their filters and boosts; when only intents are left,
+// every document of the catalog that passes the filters and exclusions is
+// ranked.
+func performSearch(backend search.SearchBackend, catalog *search.Catalog, query search.Query, interpretation *search.Interpretation) ([]SearchResult, error) {
+	pipeline := RankingPipeline
+	if interpretation != nil {
+		query = search.Query{Text: interpretation.Expr.String(), Expr: interpretation.Expr, Filter: interpretation.Filter(query.Filter)}
+		pipeline = pipeline.With(interpretation.Boosts()...)
 	}
-	defer rows.Close()
 
-	// Iterate through the query results and construct search results
-	var results []SearchResult
-	for rows.Next() {
-		var id int
-		var title string
-		var rating float64
-		var itemType string
-		err := rows.Scan(&id, &title, &rating, &itemType)
-		if err != nil {
-            return nil, err
-        }
-
-		result := SearchResult{
-			ID:     id,
-			Title:  title,
-			Rating: rating,
-			Type:   itemType,
+	var hits []search.Hit
+	if interpretation != nil && interpretation.Browse() {
+		hits = catalog.Browse(query.Filter, query.Expr)
+	} else {
+		var err error
+		if hits, err = backend.Search(query); err != nil {
+			return nil, err
 		}
-		results = append(results, result)
 	}
 
-	// Sort the results by relevance
-    sortResults(results, searchQuery)
-
-    // Check for any errors during iteration
-    err = rows.Err()
-    if err != nil {
-        return nil, err
-    }
+	var results []SearchResult
+	now := time.Now()
+	for _, hit := range pipeline.Rank(query.Expr.Text(), hits) {
+		results = append(results, SearchResult{
+			ID:     hit.Doc.ID,
+			Title:  hit.Doc.Title,
+			Rating: hit.Doc.Rating,
+			Type:   hit.Doc.Type,
+			Timestamp: now,
+			Score:  hit.Total,
+			Scores: hit.Scores,
+			MatchedFields: hit.MatchedFields,
+			Typos:  hit.Typos,
+			doc:    hit.Doc,
+			matcher: hit.Matcher,
+		})
+	}
 
 	return results, nil
 }
 
-// sortResults sorts the search results by relevance
-func sortResults(results []SearchResult, searchQuery string) {
-    // Define a relevance score for each search result
-    for i, result := range results {
-        // Calculate the relevance score based on factors like exact match and partial match
-        score := calculateRelevanceScore(result.Title, searchQuery)
-        // Assign the relevance score to the search result
-        results[i].RelevanceScore = score
-    }
-
-    // Sort the results by relevance score (in descending order)
-    sort.Slice(results, func(i, j int) bool {
-        // If relevance scores are equal, prioritize shorter titles
-        if results[i].RelevanceScore == results[j].RelevanceScore {
-            return len(results[i].Title) < len(results[j].Title)
-        }
-        return results[i].RelevanceScore > results[j].RelevanceScore
-    })
+// highlight returns a copy of the page with the highlights of every result
+// and, when a snippet format is given, their snippets. The page is copied
+// because it shares its results with the cache.
+func highlight(page []SearchResult, snippetFormat string) []SearchResult {
+	highlighted := make([]SearchResult, len(page))
+	for i, result := range page {
+		result.Highlights = search.Highlights(result.doc, result.matcher)
+		if snippetFormat != "" {
+			result.Snippet = search.Snippet(result.doc, result.matcher, snippetFormat)
+		}
+		highlighted[i] = result
+	}
+	return highlighted
 }
 
-// calculateRelevanceScore calculates the relevance score for a search result
-func calculateRelevanceScore(title, searchQuery string) int {
-    // Initialize the relevance score
-    score := 0
-
-    // Check for exact match
-    if strings.EqualFold(title, searchQuery) {
-        score += 1000 // Add a high score for exact match
-    }
-
-    // Check for partial match
-    if strings.Contains(strings.ToLower(title), strings.ToLower(searchQuery)) {
-        score += 500 // Add a score for partial match
-    }
-
-    // You can add more relevancy metrics here
-
-    return score
+// facets counts the requested facets over every result, not just the page
+func facets(results []SearchResult, names []string) map[string][]search.FacetCount {
+	if len(names) == 0 {
+		return nil
+	}
+	docs := make([]*search.Document, len(results))
+	for i, result := range results {
+		docs[i] = result.doc
+	}
+	return search.Facets(docs, names)
 }
 
 func generateSearchID() string {
@@ -221,41 +371,3 @@ func generateSearchID() string {
     // Convert the byte slice to a string and return it as the search ID
     return string(result)
 }
-
-// isExpired checks if a cached entry is expired based on its timestamp
-func isExpired(timestamp time.Time) bool {
-	return time.Since(timestamp) > cacheExpiration
-}
-
-// LevenshteinDistance calculates the Levenshtein distance between two strings
-func LevenshteinDistance(s1, s2 string) int {
-    m, n := len(s1), len(s2)
-    matrix := make([][]int, m+1)
-    for i := range matrix {
-        matrix[i] = make([]int, n+1)
-        matrix[i][0] = i
-    }
-    for j := 0; j <= n; j++ {
-        matrix[0][j] = j
-    }
-    for i := 1; i <= m; i++ {
-        for j := 1; j <= n; j++ {
-            cost := 0
-            if s1[i-1] != s2[j-1] {
-                cost = 1
-            }
-            matrix[i][j] = min(matrix[i-1][j]+1, matrix[i][j-1]+1, matrix[i-1][j-1]+cost)
-        }
-    }
-    return matrix[m][n]
-}
-
-// min returns the minimum of three integers
-func min(a, b, c int) int {
-    if a <= b && a <= c {
-        return a
-    } else if b <= a && b <= c {
-        return b
-    }
-    return c
-}
@@ -0,0 +1,221 @@
+package endpoints
+
+import (
+	"anghami-exercise/search"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postSearch sends the request to the search handler and decodes the response
+func postSearch(t *testing.T, handler http.HandlerFunc, request map[string]interface{}) SearchResponse {
+	t.Helper()
+	body, _ := json.Marshal(request)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/search", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", body, w.Code, w.Body)
+	}
+	var response SearchResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return response
+}
+
+// newTestCatalog indexes the documents in a catalog over a memory backend
+func newTestCatalog(docs ...*search.Document) (search.SearchBackend, *search.Catalog) {
+	backend := search.NewMemoryBackend()
+	catalog := search.NewCatalog(backend)
+	catalog.Load(docs)
+	return backend, catalog
+}
+
+func TestSearchCachedOrder(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend, catalog := newTestCatalog(
+		&search.Document{ID: 1, Type: "book", Title: "Harry Potter and the Chamber of Secrets", Rating: 4.4, RatingsCount: 500, Year: 1998},
+		&search.Document{ID: 2, Type: "book", Title: "Harry Potter and the Goblet of Fire", Rating: 4.6, RatingsCount: 20000, Year: 2000},
+		&search.Document{ID: 3, Type: "movie", Title: "Harry Potter and the Goblet of Fire", Rating: 7.7, Year: 2005},
+		&search.Document{ID: 4, Type: "movie", Title: "When Harry Met Sally", Rating: 7.6, Year: 1989},
+		&search.Document{ID: 5, Type: "book", Title: "Dirty Harry", Summary: "Harry Callahan", Year: 1971},
+	)
+	handler := SearchHandler(backend, catalog)
+
+	for _, request := range []map[string]interface{}{
+		{"search_query": "harry potter goblet"},
+		{"search_query": "harry"},
+		{"search_query": "harry", "sort": []string{"rating"}},
+	} {
+		SearchCache.Clear()
+		cold := postSearch(t, handler, request)
+		warm := postSearch(t, handler, request)
+		if cold.Cached || !warm.Cached {
+			t.Fatalf("%v: cached %v then %v, want false then true", request, cold.Cached, warm.Cached)
+		}
+		if len(cold.Results) == 0 {
+			t.Fatalf("%v: no results", request)
+		}
+		if !reflect.DeepEqual(cold.Results, warm.Results) {
+			t.Errorf("%v: cached results %+v differ from the searched %+v", request, warm.Results, cold.Results)
+		}
+	}
+}
+
+func TestSearchMatchedFields(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend, catalog := newTestCatalog(
+		&search.Document{ID: 1, Type: "book", Title: "The Hobbit", Authors: "J.R.R. Tolkien"},
+		&search.Document{ID: 2, Type: "movie", Title: "Lost in Translation", Cast: "Bill Murray, Scarlett Johansson"},
+		&search.Document{ID: 3, Type: "movie", Title: "Arrival", Summary: "A linguist is recruited to talk with aliens"},
+		&search.Document{ID: 4, Type: "book", Title: "Tolkien", Authors: "Raymond Edwards"},
+	)
+	handler := SearchHandler(backend, catalog)
+
+	tests := []struct {
+		query string
+		want  map[int][]string
+	}{
+		{"tolkien", map[int][]string{1: {"authors"}, 4: {"title"}}},
+		{"murray", map[int][]string{2: {"cast"}}},
+		{"linguist", map[int][]string{3: {"summary"}}},
+		{"author:tolkien", map[int][]string{1: {"authors"}}},
+	}
+	for _, test := range tests {
+		got := make(map[int][]string)
+		for _, result := range postSearch(t, handler, map[string]interface{}{"search_query": test.query}).Results {
+			got[result.ID] = result.MatchedFields
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Search(%q) matched fields = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSearchFacetsWithFilter(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend, catalog := newTestCatalog(
+		&search.Document{ID: 1, Type: "book", Title: "Harry Potter and the Chamber of Secrets", Year: 1998, LanguageCode: "eng"},
+		&search.Document{ID: 2, Type: "book", Title: "Harry Potter y la cámara secreta", Year: 1999, LanguageCode: "spa"},
+		&search.Document{ID: 3, Type: "book", Title: "Harry Potter and the Goblet of Fire", Year: 2000, LanguageCode: "en-US"},
+		&search.Document{ID: 4, Type: "movie", Title: "Harry Potter and the Goblet of Fire", Year: 2005},
+	)
+	handler := SearchHandler(backend, catalog)
+
+	// Facets count every match of the filter, not only the page
+	response := postSearch(t, handler, map[string]interface{}{
+		"search_query": "harry potter",
+		"filters":      map[string]interface{}{"language": "eng"},
+		"facets":       []string{"type", "language_code", "decade"},
+		"limit":        1,
+	})
+	want := map[string][]search.FacetCount{
+		"type":          {{Value: "book", Count: 2}},
+		"language_code": {{Value: "en-US", Count: 1}, {Value: "eng", Count: 1}},
+		"decade":        {{Value: "1990s", Count: 1}, {Value: "2000s", Count: 1}},
+	}
+	if len(response.Results) != 1 || response.TotalHits != 2 || !reflect.DeepEqual(response.Facets, want) {
+		t.Errorf("response has %d results of %d and facets %v, want 1 of 2 and %v", len(response.Results), response.TotalHits, response.Facets, want)
+	}
+}
+
+func TestSearchSuggestion(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend, catalog := newTestCatalog(
+		&search.Document{ID: 1, Type: "movie", Title: "Marathon", Summary: "A man running from his past"},
+		&search.Document{ID: 2, Type: "book", Title: "The Hobbit"},
+	)
+	handler := SearchHandler(backend, catalog)
+
+	tests := []struct {
+		request       map[string]interface{}
+		want          []int
+		suggestion    string
+		autoCorrected bool
+	}{
+		// Summaries are stemmed, so the typo is too far from the indexed
+		// "run", but the speller knows the word
+		{map[string]interface{}{"search_query": "runnong"}, nil, "running", false},
+		{map[string]interface{}{"search_query": "runnong", "auto_correct": true}, []int{1}, "running", true},
+		// Queries with results are never replaced
+		{map[string]interface{}{"search_query": "hobit", "auto_correct": true}, []int{2}, "hobbit", false},
+		{map[string]interface{}{"search_query": "hobbit"}, []int{2}, "", false},
+	}
+	for _, test := range tests {
+		response := postSearch(t, handler, test.request)
+		var got []int
+		for _, result := range response.Results {
+			got = append(got, result.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) || response.Suggestion != test.suggestion || response.AutoCorrected != test.autoCorrected {
+			t.Errorf("%v: results %v, suggestion %q, auto corrected %v, want %v, %q, %v",
+				test.request, got, response.Suggestion, response.AutoCorrected, test.want, test.suggestion, test.autoCorrected)
+		}
+	}
+}
+
+func TestSearchBrowseExclusions(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend, catalog := newTestCatalog(
+		&search.Document{ID: 1, Type: "movie", Title: "Batman Begins", Year: 2005},
+		&search.Document{ID: 2, Type: "movie", Title: "Inception", Year: 2010},
+		&search.Document{ID: 3, Type: "movie", Title: "The Dark Knight", Summary: "Batman faces the Joker", Year: 2008},
+		&search.Document{ID: 4, Type: "book", Title: "Batman: Year One"},
+	)
+	handler := SearchHandler(backend, catalog)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"movies", []int{1, 2, 3}},
+		{"movies -batman", []int{2}},
+		{`movies -"dark knight"`, []int{1, 2}},
+		{"movies -title:batman", []int{2, 3}},
+	}
+	for _, test := range tests {
+		response := postSearch(t, handler, map[string]interface{}{"search_query": test.query})
+		var got []int
+		for _, result := range response.Results {
+			got = append(got, result.ID)
+		}
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q returned %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSearchFiltersConflictWithQuery(t *testing.T) {
+	withCacheTTLs(t, time.Hour, time.Hour, 0)
+	backend, catalog := newTestCatalog(
+		&search.Document{ID: 1, Type: "movie", Title: "Dune", Year: 2021},
+		&search.Document{ID: 2, Type: "book", Title: "Dune", LanguageCode: "eng"},
+	)
+	handler := SearchHandler(backend, catalog)
+
+	tests := []struct {
+		request map[string]interface{}
+		status  int
+	}{
+		// The language filter only applies to books, and the query asks for movies
+		{map[string]interface{}{"search_query": "dune movies", "filters": map[string]interface{}{"language": "eng"}}, http.StatusBadRequest},
+		{map[string]interface{}{"search_query": "dune movies", "filters": map[string]interface{}{"pages": map[string]int{"min": 100}}}, http.StatusBadRequest},
+		// A type in the filters takes precedence over the query
+		{map[string]interface{}{"search_query": "dune movies", "filters": map[string]interface{}{"type": "book", "language": "eng"}}, http.StatusOK},
+		{map[string]interface{}{"search_query": "dune books", "filters": map[string]interface{}{"language": "eng"}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		body, _ := json.Marshal(test.request)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/search", strings.NewReader(string(body))))
+		if w.Code != test.status {
+			t.Errorf("%s: status %d (%s), want %d", body, w.Code, strings.TrimSpace(w.Body.String()), test.status)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package endpoints
+
+import (
+	"anghami-exercise/search"
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortKey is one key of the sort parameter, such as "rating:desc"
+type sortKey struct {
+	field      string
+	descending bool
+}
+
+// sortFields maps every sortable field to its default direction
+var sortFields = map[string]bool{
+	"relevance":  true,
+	"rating":     true,
+	"popularity": true,
+	"date":       true,
+	"title":      false,
+}
+
+// parseSort validates the sort parameter. Each key is a field, optionally
+// followed by ":asc" or ":desc".
+func parseSort(keys []string) ([]sortKey, error) {
+	parsed := make([]sortKey, 0, len(keys))
+	for _, key := range keys {
+		field, direction, hasDirection := strings.Cut(key, ":")
+		descending, ok := sortFields[field]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q, expected relevance, rating, popularity, date or title", field)
+		}
+		if hasDirection {
+			switch direction {
+			case "asc":
+				descending = false
+			case "desc":
+				descending = true
+			default:
+				return nil, fmt.Errorf("sort direction of %q must be asc or desc", field)
+			}
+		}
+		parsed = append(parsed, sortKey{field: field, descending: descending})
+	}
+	return parsed, nil
+}
+
+// sortResults returns the relevance-ranked results ordered by the sort keys.
+// Relevance breaks any remaining tie, so the order is stable across pages.
+func sortResults(results []SearchResult, keys []sortKey) []SearchResult {
+	if len(keys) == 0 || (len(keys) == 1 && keys[0] == sortKey{field: "relevance", descending: true}) {
+		return results
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &results[order[i]], &results[order[j]]
+		for _, key := range keys {
+			c := compareResults(key.field, a, b, order[i], order[j])
+			if c == 0 {
+				continue
+			}
+			if key.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return order[i] < order[j]
+	})
+
+	sorted := make([]SearchResult, len(results))
+	for i, index := range order {
+		sorted[i] = results[index]
+	}
+	return sorted
+}
+
+// compareResults compares two results by one field, returning a negative
+// number when a sorts before b in ascending order. ia and ib are the results'
+// positions in the relevance ranking.
+func compareResults(field string, a, b *SearchResult, ia, ib int) int {
+	switch field {
+	case "relevance":
+		// Better ranked results are "greater", so relevance:desc is the ranking order
+		return cmp.Compare(ib, ia)
+	case "rating":
+		// Books are rated out of 5 and movies out of 10
+		return cmp.Compare(search.NormalizedRating(a.doc), search.NormalizedRating(b.doc))
+	case "popularity":
+		// Movies have no ratings_count, so they count as unrated books and
+		// fall back to their rating, as do books with as many ratings
+		if c := cmp.Compare(a.doc.RatingsCount, b.doc.RatingsCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(search.NormalizedRating(a.doc), search.NormalizedRating(b.doc))
+	case "date":
+		return cmp.Compare(a.doc.Year, b.doc.Year)
+	case "title":
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	}
+	return 0
+}
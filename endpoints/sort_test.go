@@ -0,0 +1,79 @@
+package endpoints
+
+import (
+	"anghami-exercise/search"
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		keys    []string
+		want    []sortKey
+		wantErr bool
+	}{
+		{keys: nil, want: []sortKey{}},
+		{keys: []string{"rating"}, want: []sortKey{{field: "rating", descending: true}}},
+		{keys: []string{"title"}, want: []sortKey{{field: "title"}}},
+		{keys: []string{"date:asc", "title:desc"}, want: []sortKey{{field: "date"}, {field: "title", descending: true}}},
+		{keys: []string{"price"}, wantErr: true},
+		{keys: []string{"rating:up"}, wantErr: true},
+		{keys: []string{"rating:"}, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseSort(test.keys)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseSort(%q) = %v, want an error", test.keys, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseSort(%q) = %v, %v, want %v", test.keys, got, err, test.want)
+		}
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	docs := []*search.Document{
+		{ID: 1, Type: "movie", Title: "b", Rating: 8, Year: 2001},
+		{ID: 2, Type: "book", Title: "A", Rating: 4.5, RatingsCount: 10000, Year: 1999},
+		{ID: 3, Type: "book", Title: "c", Rating: 4.5, RatingsCount: 10, Year: 2001},
+		{ID: 4, Type: "movie", Title: "d", Rating: 6, Year: 2010},
+		{ID: 5, Type: "book", Title: "e", Rating: 4.5, RatingsCount: 2000000, Year: 2005},
+	}
+	results := make([]SearchResult, len(docs))
+	for i, doc := range docs {
+		results[i] = SearchResult{ID: doc.ID, Title: doc.Title, Type: doc.Type, Rating: doc.Rating, doc: doc}
+	}
+
+	tests := []struct {
+		sort []string
+		want []int
+	}{
+		{sort: nil, want: []int{1, 2, 3, 4, 5}},
+		{sort: []string{"relevance:asc"}, want: []int{5, 4, 3, 2, 1}},
+		// 4.5/5 is above 8/10, and the tied books keep their relevance order
+		{sort: []string{"rating"}, want: []int{2, 3, 5, 1, 4}},
+		{sort: []string{"rating:asc"}, want: []int{4, 1, 2, 3, 5}},
+		// Books by ratings_count, well past the 10,000 ratings at which the
+		// ranking fully trusts a rating, then movies by rating
+		{sort: []string{"popularity"}, want: []int{5, 2, 3, 1, 4}},
+		{sort: []string{"popularity:asc"}, want: []int{4, 1, 3, 2, 5}},
+		{sort: []string{"date", "title"}, want: []int{4, 5, 1, 3, 2}},
+		{sort: []string{"title"}, want: []int{2, 1, 3, 4, 5}},
+	}
+	for _, test := range tests {
+		keys, err := parseSort(test.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, result := range sortResults(results, keys) {
+			got = append(got, result.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("sortResults(%q) = %v, want %v", test.sort, got, test.want)
+		}
+	}
+}
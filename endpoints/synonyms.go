@@ -0,0 +1,94 @@
+package endpoints
+
+import (
+	"anghami-exercise/search"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type SynonymsResponse struct {
+	Rules []search.SynonymRule `json:"rules"`
+}
+
+// SynonymsHandler handles the /admin/synonyms endpoint: GET lists the rules,
+// POST adds a rule and DELETE ?id= removes one
+func SynonymsHandler(store *search.SynonymStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sendSynonyms(w, store)
+
+		case http.MethodPost:
+			var rule search.SynonymRule
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&rule); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := rule.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			rule, err := store.Add(rule)
+			if err != nil {
+				log.Printf("Error adding synonym rule: %v", err)
+				http.Error(w, "Error adding synonym rule", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(rule)
+
+		case http.MethodDelete:
+			id, err := strconv.Atoi(r.URL.Query().Get("id"))
+			if err != nil {
+				http.Error(w, "id must be a rule ID", http.StatusBadRequest)
+				return
+			}
+			err = store.Delete(id)
+			if errors.Is(err, search.ErrSynonymNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Error deleting synonym rule: %v", err)
+				http.Error(w, "Error deleting synonym rule", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// ReloadSynonymsHandler handles the /admin/synonyms/reload endpoint, which
+// applies changes made directly to the synonyms file or table
+func ReloadSynonymsHandler(store *search.SynonymStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := store.Reload(); err != nil {
+			log.Printf("Error reloading synonyms: %v", err)
+			http.Error(w, "Error reloading synonyms: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendSynonyms(w, store)
+	}
+}
+
+func sendSynonyms(w http.ResponseWriter, store *search.SynonymStore) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(SynonymsResponse{Rules: store.Rules()})
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
@@ -1,6 +1,7 @@
 package importCSV
 
 import (
+	"anghami-exercise/changes"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
@@ -14,6 +15,12 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// tableTypes maps the imported tables to the content type they hold
+var tableTypes = map[string]string{
+	"books":  "book",
+	"movies": "movie",
+}
+
 type CustomCSVReader struct {
     *csv.Reader
 }
@@ -73,7 +80,12 @@ func ImportDataFromCSV(db *sql.DB, fileName, tableName string) error {
 
 	reader := NewCustomCSVReader(file)
 
-	return importData(db, reader, tableName)
+	// The table is truncated before it is reloaded, so every item of its type
+	// may have changed even when the import fails part way
+	err = importData(db, reader, tableName)
+	changes.Publish(changes.Change{Type: tableTypes[tableName], Source: "import"})
+
+	return err
 }
 
 
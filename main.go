@@ -1,14 +1,18 @@
 package main
 
 import (
+	"anghami-exercise/changes"
 	"anghami-exercise/endpoints"
 	"anghami-exercise/importCSV"
 	"anghami-exercise/analytics"
+	"anghami-exercise/search"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
@@ -22,9 +26,20 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	// Initialize the searchCache map
-    endpoints.SearchCache = make(map[string][]endpoints.SearchResult)
-	
+	// Search cache bounds, e.g. SEARCH_CACHE_MAX_ENTRIES=10000 and SEARCH_CACHE_MAX_BYTES=67108864
+	maxEntries, maxBytes := endpoints.DefaultCacheEntries, int64(endpoints.DefaultCacheBytes)
+	if value := os.Getenv("SEARCH_CACHE_MAX_ENTRIES"); value != "" {
+		if maxEntries, err = strconv.Atoi(value); err != nil {
+			log.Fatalf("Error parsing SEARCH_CACHE_MAX_ENTRIES: %v", err)
+		}
+	}
+	if value := os.Getenv("SEARCH_CACHE_MAX_BYTES"); value != "" {
+		if maxBytes, err = strconv.ParseInt(value, 10, 64); err != nil {
+			log.Fatalf("Error parsing SEARCH_CACHE_MAX_BYTES: %v", err)
+		}
+	}
+	endpoints.ConfigureSearchCache(maxEntries, maxBytes)
+
 	// Database connection setup
 	dbUser := os.Getenv("DB_USER")
 	dbPass := os.Getenv("DB_PASS")
@@ -43,11 +58,76 @@ func main() {
 		log.Fatalf("Error creating tables: %v", err)
 	}
 
+	// Per-field BM25 parameters, e.g. SEARCH_BM25_PARAMS=title=1.2:0.5
+	if value := os.Getenv("SEARCH_BM25_PARAMS"); value != "" {
+		params, err := search.ParseBM25Params(value)
+		if err != nil {
+			log.Fatalf("Error parsing SEARCH_BM25_PARAMS: %v", err)
+		}
+		for field, p := range params {
+			search.FieldBM25Params[field] = p
+		}
+	}
+
+	// Per-field match weights, e.g. SEARCH_FIELD_WEIGHTS=title=3,authors=2
+	if value := os.Getenv("SEARCH_FIELD_WEIGHTS"); value != "" {
+		weights, err := search.ParseFieldWeights(value)
+		if err != nil {
+			log.Fatalf("Error parsing SEARCH_FIELD_WEIGHTS: %v", err)
+		}
+		for field, weight := range weights {
+			search.FieldWeights[field] = weight
+		}
+	}
+
+	// Search backend setup, selected with SEARCH_BACKEND (memory, mysql or meilisearch)
+	backend, err := search.NewBackend(os.Getenv("SEARCH_BACKEND"), db, os.Getenv("MEILISEARCH_HOST"), os.Getenv("MEILISEARCH_KEY"))
+	if err != nil {
+		log.Fatalf("Error creating search backend: %v", err)
+	}
+
+	// Index the catalog and re-sync it after every catalog change
+	catalog := search.NewCatalog(backend)
+	if err := catalog.Sync(db); err != nil {
+		log.Printf("Error indexing search catalog: %v", err)
+	}
+	changes.Subscribe(func(change changes.Change) {
+		if err := catalog.Sync(db); err != nil {
+			log.Printf("Error re-indexing search catalog after a change from %s: %v", change.Source, err)
+		}
+	})
+	// Cached searches are dropped once the index has the changes
+	changes.Subscribe(endpoints.InvalidateCatalog)
+
+	// Spelling suggestions also learn from past queries that led to clicks
+	if err := catalog.SyncQueries(db); err != nil {
+		log.Printf("Error loading past queries: %v", err)
+	}
+	catalog.WatchQueries(db, 5*time.Minute)
+
+	// Synonyms, loaded from SEARCH_SYNONYMS (a file path, or "db" for the synonyms table)
+	synonymSource, err := search.NewSynonymSource(os.Getenv("SEARCH_SYNONYMS"), db)
+	if err != nil {
+		log.Fatalf("Error setting up synonyms: %v", err)
+	}
+	synonyms := search.NewSynonymStore(synonymSource)
+	synonyms.OnChange = endpoints.InvalidateSearches
+	if err := synonyms.Reload(); err != nil {
+		log.Printf("Error loading synonyms: %v", err)
+	}
+	synonyms.Watch(30 * time.Second)
+
 	// Define HTTP routes
-	http.HandleFunc("/search", endpoints.SearchHandler(db))
+	http.HandleFunc("/search", endpoints.SearchHandler(backend, catalog))
+	http.HandleFunc("/autocomplete", endpoints.AutocompleteHandler(catalog))
 	http.HandleFunc("/report-search", endpoints.ReportSearchHandler(db))
 	http.HandleFunc("/report-click", endpoints.ReportClickHandler(db))
 
+	// Admin routes
+	http.HandleFunc("/admin/synonyms", endpoints.SynonymsHandler(synonyms))
+	http.HandleFunc("/admin/synonyms/reload", endpoints.ReloadSynonymsHandler(synonyms))
+	http.HandleFunc("/admin/cache", endpoints.CacheHandler())
+
 	// Jobs routes
 	http.HandleFunc("/import-books", importCSV.ImportBooksHandler(db))
 	http.HandleFunc("/import-movies", importCSV.ImportMoviesHandler(db))
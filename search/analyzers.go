@@ -0,0 +1,75 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"slices"
+	"strings"
+)
+
+// FieldAnalysis configures the analysis chain of each searchable field.
+// Stopwords and stemming use the language of the document at index time
+// and every language at query time. Fields without options, such as names,
+// are only lowercased and folded. Titles keep their stopwords so that
+// titles like "It" can still be found.
+var FieldAnalysis = map[string]analysis.Options{
+	"title":         {Stem: true},
+	"short_summary": {Stopwords: true, Stem: true},
+	"summary":       {Stopwords: true, Stem: true},
+}
+
+// languages are the languages documents are analyzed in, "" standing for
+// every language without stopwords or a stemmer
+var languages = append([]string{""}, analysis.Languages...)
+
+// analyzers holds the chain of every field in every language
+var analyzers = make(map[string]map[string]*analysis.Analyzer)
+
+// normalizer lowercases and folds words without removing or stemming them
+var normalizer = analysis.Chain("", analysis.Options{})
+
+func init() {
+	for _, field := range SearchableFields {
+		analyzers[field] = make(map[string]*analysis.Analyzer)
+		for _, language := range languages {
+			analyzers[field][language] = analysis.Chain(language, FieldAnalysis[field])
+		}
+	}
+}
+
+// documentLanguage returns the language a document is analyzed in. Books
+// use their language code; every movie in the catalog is in English.
+func documentLanguage(doc *Document) string {
+	if doc.Type == "movie" {
+		return analysis.English
+	}
+	return analysis.Language(doc.LanguageCode)
+}
+
+// fieldAnalyzer returns the analysis chain of a field of the document
+func fieldAnalyzer(doc *Document, field string) *analysis.Analyzer {
+	return analyzers[field][documentLanguage(doc)]
+}
+
+// analyzeField returns the indexed terms of a field of the document
+func analyzeField(doc *Document, field string) []analysis.Token {
+	return fieldAnalyzer(doc, field).Analyze(doc.FieldValue(field))
+}
+
+// queryForms returns the terms a query word can match in a field: its
+// analyzed form in each of the languages, without duplicates. It is empty
+// when the word is a stopword in all of them.
+func queryForms(field, word string, languages []string) []string {
+	var forms []string
+	for _, language := range languages {
+		if term := analyzers[field][language].Term(word); term != "" && !slices.Contains(forms, term) {
+			forms = append(forms, term)
+		}
+	}
+	return forms
+}
+
+// normalizeWords lowercases and folds the words of text and joins them with
+// single spaces
+func normalizeWords(text string) string {
+	return strings.Join(normalizer.Terms(text), " ")
+}
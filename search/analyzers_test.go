@@ -0,0 +1,53 @@
+package search
+
+import "testing"
+
+func TestIndexSearchAnalysis(t *testing.T) {
+	ix := NewIndex([]*Document{
+		{ID: 1, Type: "book", Title: "He Runs", LanguageCode: "eng"},
+		{ID: 2, Type: "book", Title: "Le Pré aux Clercs", LanguageCode: "fre"},
+		{ID: 3, Type: "movie", Title: "Marathon", Summary: "A man running from the past"},
+		{ID: 4, Type: "book", Title: "Los Libros", LanguageCode: "spa"},
+		{ID: 5, Type: "movie", Title: "Rings", Summary: "The lord of the rings"},
+	})
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"running", []int{1, 3}},
+		{"Pre", []int{2}},
+		{"pré aux", []int{2}},
+		{"libro", []int{4}},
+		{`"man running"`, []int{3}},
+		{`"lord of the rings"`, []int{5}},
+		{`"lord rings"`, nil},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		var got []int
+		for _, hit := range ix.Search(expr) {
+			got = append(got, hit.Doc.ID)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("Search(%q) = %v, want %v", test.query, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("Search(%q) = %v, want %v", test.query, got, test.want)
+				break
+			}
+		}
+	}
+
+	expr, _ := ParseQuery("pre running")
+	doc := &Document{Type: "book", Title: "Pré running", LanguageCode: "eng"}
+	highlights := Highlights(doc, &Matcher{plan: ix.stats.planQuery(expr)})
+	if len(highlights) != 2 || highlights[0].End != 3 || highlights[1].Start != 4 {
+		t.Errorf("Highlights = %+v, want Pré and running", highlights)
+	}
+}
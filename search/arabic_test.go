@@ -0,0 +1,50 @@
+package search
+
+import (
+	"encoding/csv"
+	"os"
+	"testing"
+)
+
+// TestArabicTitles runs the queries of testdata/arabic_titles.csv against
+// an index of their titles, checking that each finds its title whatever the
+// spelling variants, diacritics or script of the query
+func TestArabicTitles(t *testing.T) {
+	file, err := os.Open("testdata/arabic_titles.csv")
+	if err != nil {
+		t.Fatalf("error opening test corpus: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading test corpus: %v", err)
+	}
+	rows = rows[1:]
+
+	var docs []*Document
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if title := row[1]; !seen[title] {
+			seen[title] = true
+			docs = append(docs, &Document{ID: len(docs) + 1, Type: "book", Title: title, LanguageCode: "ara"})
+		}
+	}
+	ix := NewIndex(docs)
+
+	for _, row := range rows {
+		query, title := row[0], row[1]
+		expr, err := ParseQuery(query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", query, err)
+		}
+		var titles []string
+		found := false
+		for _, hit := range ix.Search(expr) {
+			titles = append(titles, hit.Doc.Title)
+			found = found || hit.Doc.Title == title
+		}
+		if !found {
+			t.Errorf("Search(%q) = %q, want %q", query, titles, title)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// MaxCompletions is the largest number of completions returned for a prefix
+const MaxCompletions = 20
+
+// maxCompletionKeyLength is the depth of the trie. Nodes at this depth keep
+// more completions than the others, and longer prefixes are filtered from
+// those.
+const maxCompletionKeyLength = 32
+
+// maxDeepCompletions caps the completions of the nodes at the bottom of the
+// trie. When more titles share a node's key, a prefix longer than the trie is
+// completed by scanning every title instead.
+const maxDeepCompletions = 5 * MaxCompletions
+
+// Autocompleter suggests catalog titles for a typed prefix. Every title is
+// inserted into a trie once per word, so "pot" completes "Harry Potter", and
+// every node stores its best completions, ranked by completionWeight, so a lookup
+// only walks the prefix.
+type Autocompleter struct {
+	docs    []*Document
+	keys    []string // normalized title of each document
+	weights []float64
+	order   []int32 // documents, best first
+
+	// The trie is stored in flat arrays: node i has the edges
+	// edges[nodes[i].edgeStart:][:nodes[i].edgeCount] and the completions
+	// top[nodes[i].topStart:][:nodes[i].topCount]
+	nodes []trieNode
+	edges []trieEdge
+	top   []int32
+}
+
+// trieNode is a node of the completion trie
+type trieNode struct {
+	edgeStart, edgeCount int32
+	topStart, topCount   int32
+	overflow             bool // some completions did not fit in top
+}
+
+// trieEdge links a node to the child reached by one rune
+type trieEdge struct {
+	r    rune
+	node int32
+}
+
+// buildNode is a trie node while the trie is being built
+type buildNode struct {
+	children []trieEdge
+	top      []int32 // documents, best first
+	overflow bool
+}
+
+// assumedMovieRatingsCount stands in for the ratings_count movies do not have
+const assumedMovieRatingsCount = 100000
+
+// completionWeight ranks completions by rating, weighted by ratings_count
+func completionWeight(doc *Document) float64 {
+	if doc.Type == "movie" {
+		return doc.Rating / 10 * math.Log1p(assumedMovieRatingsCount)
+	}
+	return doc.Rating / 5 * math.Log1p(float64(doc.RatingsCount))
+}
+
+// NewAutocompleter builds the completion trie of the documents' titles
+func NewAutocompleter(docs []*Document) *Autocompleter {
+	a := &Autocompleter{
+		docs:    docs,
+		keys:    make([]string, len(docs)),
+		weights: make([]float64, len(docs)),
+	}
+
+	for i, doc := range docs {
+		a.keys[i] = normalizeWords(doc.Title)
+		a.weights[i] = completionWeight(doc)
+	}
+
+	// Insert the best documents first, so each node's first completions are its top ones
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return a.better(order[i], order[j])
+	})
+
+	a.order = make([]int32, len(order))
+	nodes := []buildNode{{}}
+	for i, doc := range order {
+		a.order[i] = int32(doc)
+		key := a.keys[doc]
+		for start := 0; start < len(key); start++ {
+			if start == 0 || key[start-1] == ' ' {
+				nodes = insertCompletion(nodes, key[start:], int32(doc))
+			}
+		}
+	}
+
+	a.freeze(nodes)
+	return a
+}
+
+// better reports whether document i should be suggested before document j
+func (a *Autocompleter) better(i, j int) bool {
+	if a.weights[i] != a.weights[j] {
+		return a.weights[i] > a.weights[j]
+	}
+	if len(a.keys[i]) != len(a.keys[j]) {
+		return len(a.keys[i]) < len(a.keys[j])
+	}
+	return a.docs[i].Key() < a.docs[j].Key()
+}
+
+// insertCompletion adds the document to every node on the path of key
+func insertCompletion(nodes []buildNode, key string, doc int32) []buildNode {
+	node := int32(0)
+	depth := 0
+	for _, r := range key {
+		if depth == maxCompletionKeyLength {
+			break
+		}
+		depth++
+
+		// Find or create the child for r
+		next := int32(-1)
+		for _, edge := range nodes[node].children {
+			if edge.r == r {
+				next = edge.node
+				break
+			}
+		}
+		if next < 0 {
+			nodes = append(nodes, buildNode{})
+			next = int32(len(nodes) - 1)
+			nodes[node].children = append(nodes[node].children, trieEdge{r: r, node: next})
+		}
+		node = next
+
+		n := &nodes[node]
+		if depth < maxCompletionKeyLength {
+			if len(n.top) < MaxCompletions && !containsDoc(n.top, doc) {
+				n.top = append(n.top, doc)
+			}
+		} else if !containsDoc(n.top, doc) {
+			if len(n.top) < maxDeepCompletions {
+				n.top = append(n.top, doc)
+			} else {
+				n.overflow = true
+			}
+		}
+	}
+	return nodes
+}
+
+// freeze copies the built trie into the flat arrays used for lookups
+func (a *Autocompleter) freeze(nodes []buildNode) {
+	a.nodes = make([]trieNode, len(nodes))
+	for i, n := range nodes {
+		a.nodes[i] = trieNode{
+			edgeStart: int32(len(a.edges)),
+			edgeCount: int32(len(n.children)),
+			topStart:  int32(len(a.top)),
+			topCount:  int32(len(n.top)),
+			overflow:  n.overflow,
+		}
+		a.edges = append(a.edges, n.children...)
+		a.top = append(a.top, n.top...)
+	}
+}
+
+// Complete returns up to limit documents whose title has a word starting
+// with prefix, most popular first
+func (a *Autocompleter) Complete(prefix string, limit int) []*Document {
+	prefix = normalizeWords(prefix)
+	if prefix == "" || limit <= 0 {
+		return nil
+	}
+
+	node, depth := int32(0), 0
+	for _, r := range prefix {
+		if depth == maxCompletionKeyLength {
+			break
+		}
+
+		found := false
+		n := a.nodes[node]
+		for _, edge := range a.edges[n.edgeStart : n.edgeStart+n.edgeCount] {
+			if edge.r == r {
+				node, found = edge.node, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+		depth++
+	}
+
+	var results []*Document
+	n := a.nodes[node]
+	candidates := a.top[n.topStart : n.topStart+n.topCount]
+	if depth == maxCompletionKeyLength && n.overflow {
+		candidates = a.order
+	}
+	for _, doc := range candidates {
+		// Prefixes longer than the trie are checked against the titles
+		if depth == maxCompletionKeyLength && !hasWordPrefix(a.keys[doc], prefix) {
+			continue
+		}
+		results = append(results, a.docs[doc])
+		if len(results) == limit {
+			break
+		}
+	}
+	return results
+}
+
+// hasWordPrefix reports whether a word of key starts with prefix
+func hasWordPrefix(key, prefix string) bool {
+	for start := 0; start < len(key); start++ {
+		if (start == 0 || key[start-1] == ' ') && strings.HasPrefix(key[start:], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsDoc(docs []int32, doc int32) bool {
+	for _, d := range docs {
+		if d == doc {
+			return true
+		}
+	}
+	return false
+}
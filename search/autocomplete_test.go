@@ -0,0 +1,140 @@
+package search
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAutocompleterComplete(t *testing.T) {
+	a := NewAutocompleter([]*Document{
+		{ID: 1, Type: "book", Title: "Harry Potter and the Half-Blood Prince", Rating: 4.57, RatingsCount: 2000000},
+		{ID: 2, Type: "book", Title: "Harry Potter and the Order of the Phoenix", Rating: 4.49, RatingsCount: 2000000},
+		{ID: 3, Type: "movie", Title: "When Harry Met Sally", Rating: 7.7},
+		{ID: 4, Type: "book", Title: "Le Pré aux Clercs", Rating: 3.5, RatingsCount: 10},
+		{ID: 5, Type: "book", Title: "Harry", Rating: 4.57, RatingsCount: 2000000},
+		{ID: 6, Type: "book", Title: "Pottery Basics", Rating: 2, RatingsCount: 3},
+	})
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []int
+	}{
+		// Equal weights favor the shorter title
+		{"harry", 10, []int{5, 1, 2, 3}},
+		{"HARRY  pot", 10, []int{1, 2}},
+		{"pot", 10, []int{1, 2, 6}},
+		{"phoe", 10, []int{2}},
+		{"harry", 2, []int{5, 1}},
+		{"pre", 10, []int{4}},
+		{"pré aux", 10, []int{4}},
+		{"arry", 10, nil},
+		{"dune", 10, nil},
+		{"", 10, nil},
+		{"harry", 0, nil},
+	}
+	for _, test := range tests {
+		var got []int
+		for _, doc := range a.Complete(test.prefix, test.limit) {
+			got = append(got, doc.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Complete(%q, %d) = %v, want %v", test.prefix, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestAutocompleterLongPrefixes(t *testing.T) {
+	// More titles share their first 32 runes than the bottom of the trie
+	// keeps, and the worst rated ones are only found by their last words
+	shared := "the complete illustrated history of"
+	var docs []*Document
+	for i := 0; i < maxDeepCompletions+20; i++ {
+		docs = append(docs, &Document{
+			ID: i, Type: "book", Title: fmt.Sprintf("%s volume %03d", shared, i),
+			Rating: 5 - float64(i)/100, RatingsCount: 100,
+		})
+	}
+	docs = append(docs, &Document{ID: 1000, Type: "book", Title: shared + " rome", Rating: 1, RatingsCount: 1})
+	a := NewAutocompleter(docs)
+
+	for _, n := range a.nodes {
+		if n.topCount > maxDeepCompletions {
+			t.Fatalf("a trie node keeps %d completions, want at most %d", n.topCount, maxDeepCompletions)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   []int
+	}{
+		{shared + " rome", []int{1000}},
+		{shared + " volume 11", []int{110, 111, 112, 113, 114, 115, 116, 117, 118, 119}},
+		{shared + " volume 011", []int{11}},
+		{shared + " volume 2", nil},
+		{"illustrated history of rome", []int{1000}},
+	}
+	for _, test := range tests {
+		var got []int
+		for _, doc := range a.Complete(test.prefix, MaxCompletions) {
+			got = append(got, doc.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Complete(%q) = %v, want %v", test.prefix, got, test.want)
+		}
+	}
+}
+
+// loadBooks reads the books of books.csv with the fields the autocompleter uses
+func loadBooks(b *testing.B) []*Document {
+	file, err := os.Open("../books.csv")
+	if err != nil {
+		b.Skipf("books.csv not available: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		b.Fatalf("error reading books.csv: %v", err)
+	}
+
+	var docs []*Document
+	for _, record := range records[1:] {
+		if len(record) < 9 {
+			continue
+		}
+		id, _ := strconv.Atoi(record[0])
+		rating, _ := strconv.ParseFloat(record[3], 64)
+		count, _ := strconv.Atoi(strings.TrimSpace(record[8]))
+		docs = append(docs, &Document{ID: id, Type: "book", Title: record[1], Rating: rating, RatingsCount: count})
+	}
+	return docs
+}
+
+func BenchmarkAutocompleteBuild(b *testing.B) {
+	docs := loadBooks(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewAutocompleter(docs)
+	}
+}
+
+func BenchmarkAutocomplete(b *testing.B) {
+	docs := loadBooks(b)
+	a := NewAutocompleter(docs)
+	prefixes := []string{"h", "har", "harry pot", "the", "lord of the r", "a", "sci", "the complete works of william shakespeare"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Complete(prefixes[i%len(prefixes)], 10)
+	}
+}
@@ -0,0 +1,290 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hit is a single document matched by a search backend
+type Hit struct {
+	Doc *Document
+	// Score is the weighted BM25 relevance of the document to the query
+	Score float64
+	// MatchedFields lists the fields in which the query matched
+	MatchedFields []string
+	// Typos is the total edit distance of the query terms that only matched fuzzily
+	Typos int
+	// Matcher finds the words of the document that matched, for highlighting
+	Matcher *Matcher
+}
+
+// Query is a search request handed to a backend
+type Query struct {
+	// Text is the search query, written in the query language of ParseQuery
+	Text string
+	// Expr is the parsed Text; backends parse Text when it is nil
+	Expr *Expr
+	// Filter restricts the matching documents; nil matches everything
+	Filter *Filter
+}
+
+// expression returns the parsed query, expanded with the active synonyms
+func (q Query) expression() (*Expr, error) {
+	expr := q.Expr
+	if expr == nil {
+		var err error
+		if expr, err = ParseQuery(q.Text); err != nil {
+			return nil, err
+		}
+	}
+	return activeSynonyms.Load().Expand(expr), nil
+}
+
+// Stats describes the state of a search backend
+type Stats struct {
+	Backend     string    `json:"backend"`
+	Documents   int       `json:"documents"`
+	LastIndexed time.Time `json:"last_indexed"`
+}
+
+// SearchBackend is implemented by every engine that can answer search queries
+type SearchBackend interface {
+	// Search returns the documents matching the query text and filter
+	Search(query Query) ([]Hit, error)
+	// Index adds the documents to the backend, replacing any with the same key
+	Index(docs []*Document) error
+	// Delete removes the documents with the given keys
+	Delete(keys ...string) error
+	// Replace removes the documents with the given keys and indexes docs in
+	// a single update, as a catalog sync does
+	Replace(docs []*Document, removed []string) error
+	// Stats reports the backend's current state
+	Stats() (Stats, error)
+	// Name is the name of the backend in NewBackend
+	Name() string
+}
+
+// NewBackend creates the search backend with the given name.
+// Supported names are "memory" (the default), "mysql" and "meilisearch".
+func NewBackend(name string, db *sql.DB, meiliHost, meiliKey string) (SearchBackend, error) {
+	switch name {
+	case "", "memory":
+		return NewMemoryBackend(), nil
+	case "mysql":
+		return NewMySQLBackend(db), nil
+	case "meilisearch":
+		if meiliHost == "" {
+			return nil, fmt.Errorf("MEILISEARCH_HOST is required for the meilisearch backend")
+		}
+		return NewMeilisearchBackend(meiliHost, meiliKey, meiliIndexName), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", name)
+	}
+}
+
+// Catalog keeps a search backend, the autocomplete trie, the speller and the
+// identifier index in sync with the books and movies tables
+type Catalog struct {
+	mu           sync.Mutex
+	backend      SearchBackend
+	keys         map[string]bool
+	docs         []*Document
+	queries      map[string]int // successful past queries, for the speller
+	autocomplete atomic.Pointer[Autocompleter]
+	speller      atomic.Pointer[Speller]
+	identifiers  atomic.Pointer[map[Identifier]*Document]
+}
+
+// NewCatalog creates a catalog that feeds the given backend
+func NewCatalog(backend SearchBackend) *Catalog {
+	c := &Catalog{
+		backend: backend,
+		keys:    make(map[string]bool),
+	}
+	c.autocomplete.Store(NewAutocompleter(nil))
+	c.speller.Store(NewSpeller(nil, nil))
+	c.identifiers.Store(identifierIndex(nil))
+	return c
+}
+
+// Lookup returns the document with the identifier, or nil if there is none
+func (c *Catalog) Lookup(id Identifier) *Document {
+	return (*c.identifiers.Load())[id]
+}
+
+// identifierIndex maps the identifiers of the documents to the documents
+func identifierIndex(docs []*Document) *map[Identifier]*Document {
+	index := make(map[Identifier]*Document)
+	for _, doc := range docs {
+		for _, id := range doc.identifiers() {
+			index[id] = doc
+		}
+	}
+	return &index
+}
+
+// Browse returns every document of the catalog that passes the filter and
+// none of the excluded clauses of the query, as hits without a text score,
+// for queries made only of intents and exclusions
+func (c *Catalog) Browse(filter *Filter, expr *Expr) []Hit {
+	c.mu.Lock()
+	docs := c.docs
+	c.mu.Unlock()
+
+	// Excluded phrases also exclude their synonyms, as in a search
+	expr, _ = Query{Expr: expr}.expression()
+
+	var hits []Hit
+	for _, doc := range docs {
+		if filter.Match(doc) && !excludes(expr, doc) {
+			hits = append(hits, Hit{Doc: doc})
+		}
+	}
+	return hits
+}
+
+// excludes reports whether the document matches an excluded clause of the
+// query. The words of a clause are analyzed with the chains the document's
+// fields were indexed with, and a phrase matches where its terms occur at
+// the same distances as in the query, as in Index.Search.
+func excludes(expr *Expr, doc *Document) bool {
+	for _, clause := range expr.Clauses {
+		if !clause.Exclude {
+			continue
+		}
+		for _, op := range clause.Operands {
+			for _, field := range op.Fields() {
+				if containsOperand(doc, field, op) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// containsOperand reports whether the words of the operand appear in the
+// field of the document
+func containsOperand(doc *Document, field string, op Operand) bool {
+	analyzer := fieldAnalyzer(doc, field)
+	var terms []analysis.Token
+	for offset, word := range op.Words {
+		// Stopwords are skipped but still count for the distances
+		if term := analyzer.Term(normalizer.Term(word)); term != "" {
+			terms = append(terms, analysis.Token{Term: term, Position: offset})
+		}
+	}
+	if len(terms) == 0 {
+		return false
+	}
+
+	positions := make(map[string]map[int]bool)
+	for _, token := range analyzeField(doc, field) {
+		if positions[token.Term] == nil {
+			positions[token.Term] = make(map[int]bool)
+		}
+		positions[token.Term][token.Position] = true
+	}
+	for start := range positions[terms[0].Term] {
+		found := true
+		for _, t := range terms[1:] {
+			if !positions[t.Term][start+t.Position-terms[0].Position] {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Autocompleter returns the completion trie of the current catalog
+func (c *Catalog) Autocompleter() *Autocompleter {
+	return c.autocomplete.Load()
+}
+
+// Speller returns the speller of the current catalog and past queries
+func (c *Catalog) Speller() *Speller {
+	return c.speller.Load()
+}
+
+// SyncQueries reloads the successful past queries the speller suggests
+func (c *Catalog) SyncQueries(db *sql.DB) error {
+	queries, err := LoadSuccessfulQueries(db)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = queries
+	c.speller.Store(NewSpeller(c.docs, c.queries))
+	return nil
+}
+
+// WatchQueries reloads the successful past queries every interval
+func (c *Catalog) WatchQueries(db *sql.DB, interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := c.SyncQueries(db); err != nil {
+				log.Printf("Error reloading past queries: %v", err)
+			}
+		}
+	}()
+}
+
+// Sync loads the catalog from the database, removes documents that no longer
+// exist from the backend and (re)indexes the rest
+func (c *Catalog) Sync(db *sql.DB) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	docs, err := LoadDocuments(db)
+	if err != nil {
+		return err
+	}
+	return c.load(docs)
+}
+
+// Load replaces the documents of the catalog like Sync, from documents
+// loaded elsewhere
+func (c *Catalog) Load(docs []*Document) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load(docs)
+}
+
+// load removes the documents missing from docs from the backend, (re)indexes
+// the rest and rebuilds the autocomplete, speller and identifier indexes; the
+// caller must hold c.mu
+func (c *Catalog) load(docs []*Document) error {
+	keys := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		keys[doc.Key()] = true
+	}
+
+	var removed []string
+	for key := range c.keys {
+		if !keys[key] {
+			removed = append(removed, key)
+		}
+	}
+	if err := c.backend.Replace(docs, removed); err != nil {
+		return fmt.Errorf("error indexing documents: %v", err)
+	}
+	c.keys = keys
+	c.docs = docs
+	c.autocomplete.Store(NewAutocompleter(docs))
+	c.speller.Store(NewSpeller(docs, c.queries))
+	c.identifiers.Store(identifierIndex(docs))
+
+	log.Printf("Search catalog synced: %d documents indexed, %d removed", len(docs), len(removed))
+	return nil
+}
@@ -0,0 +1,387 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// BM25Params are the BM25 term-frequency saturation (K1) and
+// length-normalization (B) parameters of one field
+type BM25Params struct {
+	K1 float64
+	B  float64
+}
+
+// DefaultBM25Params are used for fields without their own parameters
+var DefaultBM25Params = BM25Params{K1: 1.2, B: 0.75}
+
+// FieldBM25Params holds the per-field BM25 parameters. Titles are short,
+// so they get a weaker length normalization than the default.
+var FieldBM25Params = map[string]BM25Params{
+	"title": {K1: 1.2, B: 0.5},
+}
+
+// bm25ParamsFor returns the BM25 parameters of a field
+func bm25ParamsFor(field string) BM25Params {
+	if p, ok := FieldBM25Params[field]; ok {
+		return p
+	}
+	return DefaultBM25Params
+}
+
+// ParseBM25Params parses per-field parameters written as
+// "field=k1:b,field=k1:b" (for example "title=1.2:0.5")
+func ParseBM25Params(s string) (map[string]BM25Params, error) {
+	params := make(map[string]BM25Params)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		field, values, ok := strings.Cut(entry, "=")
+		k1s, bs, ok2 := strings.Cut(values, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid BM25 parameters %q, expected field=k1:b", entry)
+		}
+
+		k1, err := strconv.ParseFloat(k1s, 64)
+		if err != nil || k1 < 0 {
+			return nil, fmt.Errorf("invalid k1 in %q", entry)
+		}
+		b, err := strconv.ParseFloat(bs, 64)
+		if err != nil || b < 0 || b > 1 {
+			return nil, fmt.Errorf("invalid b in %q, must be between 0 and 1", entry)
+		}
+
+		field = strings.TrimSpace(field)
+		if !isSearchableField(field) {
+			return nil, fmt.Errorf("unknown field in %q, expected one of %s", entry, strings.Join(SearchableFields, ", "))
+		}
+		params[field] = BM25Params{K1: k1, B: b}
+	}
+	return params, nil
+}
+
+// CorpusStats holds the catalog-wide statistics BM25 needs: the number of
+// documents, per-field document frequencies and average field lengths
+type CorpusStats struct {
+	docs      int
+	docFreq   map[string]map[string]int
+	avgLength map[string]float64
+	terms     map[string][]string            // sorted vocabulary of each field
+	languages map[string][]string            // languages the documents of each field are analyzed in
+	words     map[string]map[string][]string // the normalized words of each field, by the term they analyze to
+	fuzzy     *FuzzyDictionary
+}
+
+// prefixMatchFactor discounts terms that only match a query term as a prefix,
+// so that an exact word always outranks a longer word starting with it
+const prefixMatchFactor = 0.9
+
+// fuzzyMatchFactor discounts terms that only match a query term with typos
+const fuzzyMatchFactor = 0.5
+
+// NewCorpusStats computes the statistics of the indexed fields over docs
+func NewCorpusStats(docs []*Document) *CorpusStats {
+	stats := &CorpusStats{
+		docs:      len(docs),
+		docFreq:   make(map[string]map[string]int),
+		avgLength: make(map[string]float64),
+		terms:     make(map[string][]string),
+		languages: make(map[string][]string),
+		words:     make(map[string]map[string][]string),
+	}
+
+	vocabulary := make(map[string]int)
+	for _, field := range SearchableFields {
+		df := make(map[string]int)
+		words := make(map[string][]string)
+		total, withField := 0, 0
+		fieldLanguages := make(map[string]bool)
+		for _, doc := range docs {
+			tokens := analyzeField(doc, field)
+			docWords := analysis.Tokenize(doc.FieldValue(field))
+			total += len(tokens)
+			if len(tokens) > 0 {
+				withField++
+			}
+			if doc.FieldValue(field) != "" {
+				fieldLanguages[documentLanguage(doc)] = true
+			}
+
+			seen := make(map[string]bool, len(tokens))
+			for _, token := range tokens {
+				if !seen[token.Term] {
+					seen[token.Term] = true
+					df[token.Term]++
+				}
+				if word := normalizer.Term(docWords[token.Position]); !slices.Contains(words[token.Term], word) {
+					words[token.Term] = append(words[token.Term], word)
+				}
+			}
+		}
+		for _, language := range languages {
+			if fieldLanguages[language] {
+				stats.languages[field] = append(stats.languages[field], language)
+			}
+		}
+
+		terms := make([]string, 0, len(df))
+		for term := range df {
+			terms = append(terms, term)
+			vocabulary[term] += df[term]
+		}
+		sort.Strings(terms)
+
+		stats.docFreq[field] = df
+		stats.terms[field] = terms
+		stats.words[field] = words
+		// Average only over documents that have the field, as most fields
+		// exist only on books or only on movies
+		if withField > 0 {
+			stats.avgLength[field] = float64(total) / float64(withField)
+		}
+	}
+
+	stats.fuzzy = NewFuzzyDictionary(vocabulary)
+
+	return stats
+}
+
+// IDF returns the inverse document frequency of a term in a field
+func (s *CorpusStats) IDF(field, term string) float64 {
+	return s.idf(s.docFreq[field][term])
+}
+
+// PrefixTerms returns every term of a field starting with prefix
+func (s *CorpusStats) PrefixTerms(field, prefix string) []string {
+	terms := s.terms[field]
+	start := sort.SearchStrings(terms, prefix)
+	end := start
+	for end < len(terms) && strings.HasPrefix(terms[end], prefix) {
+		end++
+	}
+	return terms[start:end]
+}
+
+// prefixIDF treats every term starting with prefix as one term, so a short
+// prefix that matches many words is weighted like a common word
+func (s *CorpusStats) prefixIDF(field, prefix string) float64 {
+	df := 0
+	for _, term := range s.PrefixTerms(field, prefix) {
+		df += s.docFreq[field][term]
+	}
+	return s.idf(min(df, s.docs))
+}
+
+func (s *CorpusStats) idf(df int) float64 {
+	n := float64(s.docs)
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// queryTerm is an analyzed query word with its typo-tolerant alternatives
+type queryTerm struct {
+	text      string         // the lowercased and folded word
+	forms     []string       // the analyzed word in each language of the field
+	offset    int            // the position of the word in its phrase
+	last      bool           // the last word is also matched as a prefix of text
+	fuzzy     map[string]int // typo corrections and transliterations, by typos
+	prefixIDF float64
+}
+
+// operandPlan is an analyzed query operand: a single term, or the terms of
+// a phrase, matched in the given fields. Each field analyzes the words with
+// its own chain, so a stopword can be a term in one field and be dropped
+// from another.
+type operandPlan struct {
+	fields []string
+	words  []queryTerm            // the words before field analysis
+	terms  map[string][]queryTerm // per field
+}
+
+// clausePlan is an analyzed query clause
+type clausePlan struct {
+	operands []operandPlan
+	exclude  bool
+}
+
+// planQuery analyzes the words of a parsed query with the chain of every
+// field they are searched in, and looks up the fuzzy alternatives and
+// Arabizi spellings of every word that is not excluded
+func (s *CorpusStats) planQuery(expr *Expr) []clausePlan {
+	fuzzy := make(map[string][]FuzzyMatch)
+	arabizi := make(map[string][]string)
+	plan := make([]clausePlan, len(expr.Clauses))
+	for i, clause := range expr.Clauses {
+		plan[i] = clausePlan{exclude: clause.Exclude}
+		for _, op := range clause.Operands {
+			operand := operandPlan{fields: op.Fields(), terms: make(map[string][]queryTerm)}
+			for offset, word := range op.Words {
+				text := normalizer.Term(word)
+				operand.words = append(operand.words, queryTerm{text: text, forms: []string{text}, offset: offset, last: op.Prefix})
+			}
+
+			for _, field := range operand.fields {
+				for _, word := range operand.words {
+					qt := word
+					qt.forms = queryForms(field, qt.text, s.languages[field])
+					if len(qt.forms) == 0 {
+						continue
+					}
+					if qt.last {
+						qt.prefixIDF = s.prefixIDF(field, qt.text)
+					}
+					if !clause.Exclude {
+						qt.fuzzy = make(map[string]int)
+						for _, form := range qt.forms {
+							if _, ok := fuzzy[form]; !ok {
+								fuzzy[form] = s.fuzzy.Lookup(form)
+							}
+							for _, match := range fuzzy[form] {
+								if _, exact := qt.fuzzy[match.Term]; !exact && !slices.Contains(qt.forms, match.Term) {
+									qt.fuzzy[match.Term] = match.Distance
+								}
+							}
+						}
+
+						// Arabizi words also match their Arabic spellings and
+						// Latin reading, like typo corrections without typos
+						if _, ok := arabizi[qt.text]; !ok {
+							arabizi[qt.text] = analysis.Arabizi(qt.text)
+						}
+						for _, spelling := range arabizi[qt.text] {
+							for _, form := range queryForms(field, spelling, s.languages[field]) {
+								if s.docFreq[field][form] > 0 && !slices.Contains(qt.forms, form) {
+									qt.fuzzy[form] = 0
+								}
+							}
+						}
+					}
+					operand.terms[field] = append(operand.terms[field], qt)
+				}
+			}
+			plan[i].operands = append(plan[i].operands, operand)
+		}
+	}
+	return plan
+}
+
+// candidates returns the indexed terms of a field that can satisfy the query term
+func (s *CorpusStats) candidates(field string, qt queryTerm) []string {
+	terms := append([]string(nil), qt.forms...)
+	if qt.last {
+		terms = append(terms, s.PrefixTerms(field, qt.text)...)
+	}
+	for term := range qt.fuzzy {
+		if s.docFreq[field][term] > 0 {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// matchScore returns the BM25 contribution of a document term occurring freq
+// times in a field of the given length to a query term, and the number of
+// typos it took to match. ok is false when the term does not match.
+func (s *CorpusStats) matchScore(field string, qt queryTerm, term string, freq, length int) (score float64, typos int, ok bool) {
+	switch {
+	case slices.Contains(qt.forms, term):
+		return s.termScore(field, s.IDF(field, term), freq, length), 0, true
+	case qt.last && strings.HasPrefix(term, qt.text):
+		return s.prefixScore(field, qt.text, term, qt.prefixIDF, freq, length), 0, true
+	}
+
+	if distance, fuzzy := qt.fuzzy[term]; fuzzy {
+		return fuzzyMatchFactor * s.termScore(field, s.IDF(field, term), freq, length), distance, true
+	}
+	return 0, 0, false
+}
+
+// scoreDocument matches a document that was not retrieved from the
+// in-memory index against a planned query, indexing its fields on the fly,
+// so that it is matched and scored exactly like in Index.Search. ok is false
+// if the document does not match.
+func (s *CorpusStats) scoreDocument(doc *Document, plan []clausePlan) (Hit, bool) {
+	ix := newIndex([]*Document{doc}, s)
+	m, ok := ix.evaluate(plan)[0]
+	if !ok {
+		return Hit{}, false
+	}
+	return Hit{
+		Doc:           doc,
+		Score:         m.score,
+		MatchedFields: sortedFields(m.fields),
+		Typos:         m.typos,
+		Matcher:       &Matcher{plan: plan},
+	}, true
+}
+
+// termScore is the BM25 contribution of a term with the given IDF occurring
+// freq times in a field of the given length
+func (s *CorpusStats) termScore(field string, idf float64, freq, length int) float64 {
+	if freq == 0 {
+		return 0
+	}
+
+	p := bm25ParamsFor(field)
+	avg := s.avgLength[field]
+	if avg == 0 {
+		avg = 1
+	}
+
+	tf := float64(freq)
+	norm := p.K1 * (1 - p.B + p.B*float64(length)/avg)
+	return idf * tf * (p.K1 + 1) / (tf + norm)
+}
+
+// prefixScore is the BM25 contribution of a term matched by a query prefix
+func (s *CorpusStats) prefixScore(field, prefix, term string, idf float64, freq, length int) float64 {
+	score := s.termScore(field, idf, freq, length)
+	if term != prefix {
+		score *= prefixMatchFactor
+	}
+	return score
+}
+
+// corpusTracker keeps the corpus statistics of backends that store their
+// documents elsewhere (MySQL, Meilisearch) up to date as documents are
+// indexed and deleted
+type corpusTracker struct {
+	mu    sync.Mutex
+	docs  map[string]*Document
+	stats atomic.Pointer[CorpusStats]
+}
+
+func newCorpusTracker() *corpusTracker {
+	t := &corpusTracker{docs: make(map[string]*Document)}
+	t.stats.Store(NewCorpusStats(nil))
+	return t
+}
+
+// update forgets the removed documents, records docs and recomputes the
+// statistics once
+func (t *corpusTracker) update(docs []*Document, removed []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range removed {
+		delete(t.docs, key)
+	}
+	for _, doc := range docs {
+		t.docs[doc.Key()] = doc
+	}
+
+	all := make([]*Document, 0, len(t.docs))
+	for _, doc := range t.docs {
+		all = append(all, doc)
+	}
+	t.stats.Store(NewCorpusStats(all))
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseBM25Params(t *testing.T) {
+	got, err := ParseBM25Params(" title=1.5:0.3, cast=2:1,")
+	want := map[string]BM25Params{"title": {K1: 1.5, B: 0.3}, "cast": {K1: 2, B: 1}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseBM25Params = %v, %v, want %v", got, err, want)
+	}
+	if got, err := ParseBM25Params(""); err != nil || len(got) != 0 {
+		t.Errorf(`ParseBM25Params("") = %v, %v, want no parameters`, got, err)
+	}
+
+	for _, s := range []string{
+		"title",           // no values
+		"title=1.2",       // no b
+		"title=x:0.5",     // k1 is not a number
+		"title=-1:0.5",    // negative k1
+		"title=1.2:1.5",   // b above 1
+		"title=1.2:-0.1",  // b below 0
+		"rating=1.2:0.5",  // not a searchable field
+		"title=1.2:0.5;x", // b is not a number
+	} {
+		if _, err := ParseBM25Params(s); err == nil {
+			t.Errorf("ParseBM25Params(%q) returned no error", s)
+		}
+	}
+}
+
+func TestCorpusStatsBM25(t *testing.T) {
+	// The publisher field uses the default parameters, k1 1.2 and b 0.75,
+	// and its average length here is 4/3
+	s := NewCorpusStats([]*Document{
+		{ID: 1, Type: "book", Publisher: "Tor"},
+		{ID: 2, Type: "book", Publisher: "Tor Orbit"},
+		{ID: 3, Type: "book", Publisher: "Gollancz"},
+	})
+
+	idfs := []struct {
+		term string
+		want float64
+	}{
+		{"tor", math.Log(1 + 1.5/2.5)},
+		{"gollancz", math.Log(1 + 2.5/1.5)},
+		{"missing", math.Log(1 + 3.5/0.5)},
+	}
+	for _, test := range idfs {
+		if got := s.IDF("publisher", test.term); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("IDF(%q) = %v, want %v", test.term, got, test.want)
+		}
+	}
+	if s.IDF("publisher", "tor") >= s.IDF("publisher", "gollancz") {
+		t.Error("a common term weighs as much as a rare one")
+	}
+
+	idf := s.IDF("publisher", "tor")
+	scores := []struct {
+		freq, length int
+		want         float64
+	}{
+		{1, 1, idf * 2.2 / (1 + 1.2*(0.25+0.75*1/(4.0/3)))},
+		{1, 2, idf * 2.2 / (1 + 1.2*(0.25+0.75*2/(4.0/3)))},
+		{2, 2, idf * 2 * 2.2 / (2 + 1.2*(0.25+0.75*2/(4.0/3)))},
+		{0, 1, 0},
+	}
+	for _, test := range scores {
+		if got := s.termScore("publisher", idf, test.freq, test.length); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("termScore(freq %d, length %d) = %v, want %v", test.freq, test.length, got, test.want)
+		}
+	}
+	if s.termScore("publisher", idf, 1, 1) <= s.termScore("publisher", idf, 1, 2) {
+		t.Error("a match in a shorter field does not score higher")
+	}
+}
+
+func TestMatchScoreOrder(t *testing.T) {
+	// A prefix is weighted like one term occurring in every document its
+	// terms occur in, so the corpus must not be mostly made of them
+	docs := []*Document{
+		{ID: 1, Type: "book", Publisher: "Orbit"},
+		{ID: 2, Type: "book", Publisher: "Orbital"},
+		{ID: 3, Type: "book", Publisher: "Orbet"},
+	}
+	for id := 4; id <= 10; id++ {
+		docs = append(docs, &Document{ID: id, Type: "book", Publisher: "Gollancz"})
+	}
+	s := NewCorpusStats(docs)
+	expr, _ := ParseQuery("orbit")
+	qt := s.planQuery(expr)[0].operands[0].terms["publisher"][0]
+
+	// Every term occurs once in a field of one word, so only the kind of
+	// match sets the scores apart
+	exact, typos, ok := s.matchScore("publisher", qt, "orbit", 1, 1)
+	if !ok || typos != 0 {
+		t.Fatalf("orbit does not match itself exactly")
+	}
+	prefix, typos, ok := s.matchScore("publisher", qt, "orbital", 1, 1)
+	if !ok || typos != 0 {
+		t.Fatalf("orbital does not match orbit as a prefix")
+	}
+	fuzzy, typos, ok := s.matchScore("publisher", qt, "orbet", 1, 1)
+	if !ok || typos != 1 {
+		t.Fatalf("orbet does not match orbit with one typo, got %d typos", typos)
+	}
+	if !(exact > prefix && prefix > fuzzy) {
+		t.Errorf("exact %v, prefix %v and fuzzy %v scores are not in decreasing order", exact, prefix, fuzzy)
+	}
+	if _, _, ok := s.matchScore("publisher", qt, "gollancz", 1, 1); ok {
+		t.Error("gollancz matches orbit")
+	}
+}
@@ -0,0 +1,183 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document is a single searchable catalog item, either a book or a movie
+type Document struct {
+	ID           int     `json:"id"`
+	Type         string  `json:"type"`
+	Title        string  `json:"title"`
+	Rating       float64 `json:"rating"`
+	RatingsCount int     `json:"ratings_count"`
+	Year         int     `json:"year"`
+
+	// Book fields
+	Authors      string `json:"authors,omitempty"`
+	Publisher    string `json:"publisher,omitempty"`
+	LanguageCode string `json:"language_code,omitempty"`
+	NumPages     int    `json:"num_pages,omitempty"`
+	ISBN         string `json:"isbn,omitempty"`
+	ISBN13       string `json:"isbn13,omitempty"`
+
+	// Movie fields
+	Director     string `json:"director,omitempty"`
+	Writers      string `json:"writers,omitempty"`
+	Cast         string `json:"cast,omitempty"`
+	Summary      string `json:"summary,omitempty"`
+	ShortSummary string `json:"short_summary,omitempty"`
+	IMDBID       string `json:"imdb_id,omitempty"`
+}
+
+// SearchableFields lists every field that is matched against search queries
+var SearchableFields = []string{"title", "authors", "publisher", "director", "writers", "cast", "short_summary", "summary"}
+
+// Key returns an identifier that is unique across books and movies
+func (d *Document) Key() string {
+	return fmt.Sprintf("%s-%d", d.Type, d.ID)
+}
+
+// FieldValue returns the text of one of the SearchableFields
+func (d *Document) FieldValue(field string) string {
+	switch field {
+	case "title":
+		return d.Title
+	case "authors":
+		return d.Authors
+	case "publisher":
+		return d.Publisher
+	case "director":
+		return d.Director
+	case "writers":
+		return d.Writers
+	case "cast":
+		return d.Cast
+	case "summary":
+		return d.Summary
+	case "short_summary":
+		return d.ShortSummary
+	}
+	return ""
+}
+
+// isSearchableField reports whether field is one of the SearchableFields
+func isSearchableField(field string) bool {
+	for _, f := range SearchableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// bookColumns and movieColumns are the columns read by scanBook and scanMovie
+const (
+	bookColumns  = "bookID, title, average_rating, ratings_count, publication_date, authors, publisher, language_code, num_pages, isbn, isbn13"
+	movieColumns = "movieID, title, rating, year, director, writers, `cast`, summary, short_summary, imdb_id"
+)
+
+// LoadDocuments reads every book and movie from the database
+func LoadDocuments(db *sql.DB) ([]*Document, error) {
+	books, err := queryDocuments(db, "SELECT "+bookColumns+" FROM books", scanBook)
+	if err != nil {
+		return nil, fmt.Errorf("error loading books: %v", err)
+	}
+
+	movies, err := queryDocuments(db, "SELECT "+movieColumns+" FROM movies", scanMovie)
+	if err != nil {
+		return nil, fmt.Errorf("error loading movies: %v", err)
+	}
+
+	return append(books, movies...), nil
+}
+
+// queryDocuments runs a query and converts every row with scan
+func queryDocuments(db *sql.DB, query string, scan func(*sql.Rows) (*Document, error), args ...interface{}) ([]*Document, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []*Document
+	for rows.Next() {
+		doc, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+// scanBook reads a row selected with bookColumns
+func scanBook(rows *sql.Rows) (*Document, error) {
+	var id, title, rating, ratingsCount, publicationDate, authors, publisher, languageCode, numPages, isbn, isbn13 sql.NullString
+	if err := rows.Scan(&id, &title, &rating, &ratingsCount, &publicationDate, &authors, &publisher, &languageCode, &numPages, &isbn, &isbn13); err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		ID:           parseInt(id),
+		Type:         "book",
+		Title:        title.String,
+		Rating:       parseFloat(rating),
+		RatingsCount: parseInt(ratingsCount),
+		Year:         parseYear(publicationDate),
+		Authors:      authors.String,
+		Publisher:    publisher.String,
+		LanguageCode: languageCode.String,
+		NumPages:     parseInt(numPages),
+		ISBN:         strings.TrimSpace(isbn.String),
+		ISBN13:       strings.TrimSpace(isbn13.String),
+	}, nil
+}
+
+// scanMovie reads a row selected with movieColumns
+func scanMovie(rows *sql.Rows) (*Document, error) {
+	var id, title, rating, year, director, writers, cast, summary, shortSummary, imdbID sql.NullString
+	if err := rows.Scan(&id, &title, &rating, &year, &director, &writers, &cast, &summary, &shortSummary, &imdbID); err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		ID:           parseInt(id),
+		Type:         "movie",
+		Title:        title.String,
+		Rating:       parseFloat(rating),
+		Year:         parseYear(year),
+		Director:     director.String,
+		Writers:      writers.String,
+		Cast:         cast.String,
+		Summary:      summary.String,
+		ShortSummary: shortSummary.String,
+		IMDBID:       strings.TrimSpace(imdbID.String),
+	}, nil
+}
+
+// parseInt converts a VARCHAR column to an int, treating bad values as zero
+func parseInt(s sql.NullString) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s.String))
+	return n
+}
+
+// parseFloat converts a VARCHAR column to a float64, treating bad values as zero
+func parseFloat(s sql.NullString) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s.String), 64)
+	return f
+}
+
+// parseYear extracts the year from a "2017" or "9/16/2006" column
+func parseYear(s sql.NullString) int {
+	value := strings.TrimSpace(s.String)
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		value = value[i+1:]
+	}
+	year, _ := strconv.Atoi(value)
+	return year
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FacetNames lists the facets that can be requested with a search
+var FacetNames = []string{"type", "language_code", "publisher", "decade", "rating", "director"}
+
+// maxFacetValues caps the number of values returned per facet
+const maxFacetValues = 20
+
+// FacetCount is the number of matching documents with one facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ValidateFacets reports the first unknown facet name
+func ValidateFacets(names []string) error {
+	for _, name := range names {
+		if !isFacetName(name) {
+			return fmt.Errorf("unknown facet %q, expected one of %s", name, strings.Join(FacetNames, ", "))
+		}
+	}
+	return nil
+}
+
+func isFacetName(name string) bool {
+	for _, facet := range FacetNames {
+		if facet == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Facets counts the documents per value of each named facet. Documents
+// without a value for a facet, such as movies for publisher, are not counted.
+// Bucketed facets (decade and rating) are ordered by value, the others by
+// count, and each facet keeps its maxFacetValues first values.
+func Facets(docs []*Document, names []string) map[string][]FacetCount {
+	if len(names) == 0 {
+		return nil
+	}
+
+	facets := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		counts := make(map[string]int)
+		for _, doc := range docs {
+			if value := facetValue(name, doc); value != nil {
+				counts[*value]++
+			}
+		}
+
+		values := make([]FacetCount, 0, len(counts))
+		for value, count := range counts {
+			values = append(values, FacetCount{Value: value, Count: count})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if name != "decade" && name != "rating" && values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		if len(values) > maxFacetValues {
+			values = values[:maxFacetValues]
+		}
+		facets[name] = values
+	}
+	return facets
+}
+
+// facetValue returns the value of the named facet for the document, or nil
+// when the document has none
+func facetValue(name string, doc *Document) *string {
+	var value string
+	switch name {
+	case "type":
+		value = doc.Type
+	case "language_code":
+		value = doc.LanguageCode
+	case "publisher":
+		value = strings.TrimSpace(doc.Publisher)
+	case "director":
+		value = strings.TrimSpace(doc.Director)
+	case "decade":
+		if doc.Year > 0 {
+			value = fmt.Sprintf("%ds", doc.Year/10*10)
+		}
+	case "rating":
+		// One point wide buckets on the document's own scale, matching the rating filter
+		if doc.Rating > 0 {
+			low := min(int(math.Floor(doc.Rating)), 9)
+			value = fmt.Sprintf("%d-%d", low, low+1)
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	return &value
+}
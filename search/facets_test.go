@@ -0,0 +1,84 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var facetDocs = []*Document{
+	{ID: 1, Type: "book", Year: 1997, Rating: 4.47, LanguageCode: "eng", Publisher: "Scholastic"},
+	{ID: 2, Type: "book", Year: 1999, Rating: 4.41, LanguageCode: "eng", Publisher: " Scholastic "},
+	{ID: 3, Type: "book", Year: 2005, Rating: 3.9, LanguageCode: "spa", Publisher: "Salamandra"},
+	{ID: 4, Type: "movie", Year: 2001, Rating: 7.6, Director: "Chris Columbus"},
+	{ID: 5, Type: "movie", Year: 2011, Rating: 8.1, Director: "David Yates"},
+	{ID: 6, Type: "movie", Year: 2010, Rating: 10, Director: "David Yates"},
+	{ID: 7, Type: "movie", Title: "Unrated"},
+}
+
+func TestFacets(t *testing.T) {
+	got := Facets(facetDocs, FacetNames)
+	want := map[string][]FacetCount{
+		"type":          {{"movie", 4}, {"book", 3}},
+		"language_code": {{"eng", 2}, {"spa", 1}},
+		"publisher":     {{"Scholastic", 2}, {"Salamandra", 1}},
+		"director":      {{"David Yates", 2}, {"Chris Columbus", 1}},
+		"decade":        {{"1990s", 2}, {"2000s", 2}, {"2010s", 2}},
+		"rating":        {{"3-4", 1}, {"4-5", 2}, {"7-8", 1}, {"8-9", 1}, {"9-10", 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Facets = %v, want %v", got, want)
+	}
+
+	if got := Facets(facetDocs, nil); got != nil {
+		t.Errorf("Facets without names = %v, want nil", got)
+	}
+}
+
+func TestFacetsWithFilter(t *testing.T) {
+	// Facets count the documents that pass the filter, so a type filter
+	// leaves a single type and empties the facets of the other type
+	min := 2000
+	filter := &Filter{Type: "movie", Year: &IntRange{Min: &min}}
+	hits := filterHits(facetHits(), filter)
+	docs := make([]*Document, len(hits))
+	for i, hit := range hits {
+		docs[i] = hit.Doc
+	}
+
+	got := Facets(docs, []string{"type", "publisher", "director", "decade"})
+	want := map[string][]FacetCount{
+		"type":      {{"movie", 3}},
+		"publisher": {},
+		"director":  {{"David Yates", 2}, {"Chris Columbus", 1}},
+		"decade":    {{"2000s", 1}, {"2010s", 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Facets of the filtered documents = %v, want %v", got, want)
+	}
+}
+
+func TestFacetsCap(t *testing.T) {
+	var docs []*Document
+	for i := 0; i < maxFacetValues+5; i++ {
+		for j := 0; j <= i; j++ {
+			docs = append(docs, &Document{Type: "book", Publisher: fmt.Sprintf("Publisher %02d", i)})
+		}
+	}
+
+	publishers := Facets(docs, []string{"publisher"})["publisher"]
+	if len(publishers) != maxFacetValues {
+		t.Fatalf("Facets returned %d publishers, want %d", len(publishers), maxFacetValues)
+	}
+	if first := publishers[0]; first.Value != "Publisher 24" || first.Count != 25 {
+		t.Errorf("first publisher = %v, want the most frequent", first)
+	}
+}
+
+func facetHits() []Hit {
+	hits := make([]Hit, len(facetDocs))
+	for i, doc := range facetDocs {
+		hits[i] = Hit{Doc: doc}
+	}
+	return hits
+}
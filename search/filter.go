@@ -0,0 +1,163 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IntRange is an inclusive range; a nil bound is open
+type IntRange struct {
+	Min *int `json:"min"`
+	Max *int `json:"max"`
+}
+
+// FloatRange is an inclusive range; a nil bound is open
+type FloatRange struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// Filter restricts search results by structured document fields. Language
+// and page filters only apply to books, so they exclude every movie.
+type Filter struct {
+	Type     string      `json:"type,omitempty"`
+	Year     *IntRange   `json:"year,omitempty"`
+	Rating   *FloatRange `json:"rating,omitempty"`
+	Language string      `json:"language,omitempty"`
+	Pages    *IntRange   `json:"pages,omitempty"`
+}
+
+// languageCode matches the language codes of the catalog, such as "eng",
+// "fre" or "en-US"
+var languageCode = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z]{2})?$`)
+
+// Validate reports the first invalid value in the filter
+func (f *Filter) Validate() error {
+	if f == nil {
+		return nil
+	}
+
+	if f.Type != "" && f.Type != "book" && f.Type != "movie" {
+		return fmt.Errorf("filters.type must be \"book\" or \"movie\", got %q", f.Type)
+	}
+	if f.Language != "" && !languageCode.MatchString(f.Language) {
+		return fmt.Errorf("filters.language must be a language code such as \"eng\" or \"en-US\", got %q", f.Language)
+	}
+	if f.Type == "movie" && f.Language != "" {
+		return fmt.Errorf("filters.language only applies to books")
+	}
+	if f.Type == "movie" && f.Pages != nil {
+		return fmt.Errorf("filters.pages only applies to books")
+	}
+
+	if err := f.Year.validate("filters.year", 0, 9999); err != nil {
+		return err
+	}
+	if err := f.Pages.validate("filters.pages", 0, 1<<31-1); err != nil {
+		return err
+	}
+	if f.Rating != nil {
+		if (f.Rating.Min != nil && (*f.Rating.Min < 0 || *f.Rating.Min > 10)) ||
+			(f.Rating.Max != nil && (*f.Rating.Max < 0 || *f.Rating.Max > 10)) {
+			return fmt.Errorf("filters.rating bounds must be between 0 and 10")
+		}
+		if f.Rating.Min != nil && f.Rating.Max != nil && *f.Rating.Min > *f.Rating.Max {
+			return fmt.Errorf("filters.rating.min (%g) is greater than filters.rating.max (%g)", *f.Rating.Min, *f.Rating.Max)
+		}
+	}
+
+	return nil
+}
+
+// validate checks that the range is within [lo, hi] and not inverted
+func (r *IntRange) validate(name string, lo, hi int) error {
+	if r == nil {
+		return nil
+	}
+	if (r.Min != nil && (*r.Min < lo || *r.Min > hi)) || (r.Max != nil && (*r.Max < lo || *r.Max > hi)) {
+		return fmt.Errorf("%s bounds must be between %d and %d", name, lo, hi)
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return fmt.Errorf("%s.min (%d) is greater than %s.max (%d)", name, *r.Min, name, *r.Max)
+	}
+	return nil
+}
+
+// Match reports whether the document passes the filter
+func (f *Filter) Match(doc *Document) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.Type != "" && doc.Type != f.Type {
+		return false
+	}
+	if (f.Language != "" || f.Pages != nil) && doc.Type != "book" {
+		return false
+	}
+	if f.Language != "" && !languageMatches(f.Language, doc.LanguageCode) {
+		return false
+	}
+
+	return f.Year.contains(doc.Year) && f.Pages.contains(doc.NumPages) && f.Rating.contains(doc.Rating)
+}
+
+// Canonical returns an equivalent filter written one way: the language is
+// lowercased and ranges without bounds are dropped. An empty filter is nil.
+func (f *Filter) Canonical() *Filter {
+	if f.Empty() {
+		return nil
+	}
+	c := *f
+	c.Language = strings.ToLower(c.Language)
+	if c.Year != nil && c.Year.Min == nil && c.Year.Max == nil {
+		c.Year = nil
+	}
+	if c.Pages != nil && c.Pages.Min == nil && c.Pages.Max == nil {
+		c.Pages = nil
+	}
+	if c.Rating != nil && c.Rating.Min == nil && c.Rating.Max == nil {
+		c.Rating = nil
+	}
+	if c.Empty() {
+		return nil
+	}
+	return &c
+}
+
+// Empty reports whether the filter lets every document through
+func (f *Filter) Empty() bool {
+	return f == nil || (f.Type == "" && f.Year == nil && f.Rating == nil && f.Language == "" && f.Pages == nil)
+}
+
+func (r *IntRange) contains(v int) bool {
+	return r == nil || ((r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max))
+}
+
+func (r *FloatRange) contains(v float64) bool {
+	return r == nil || ((r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max))
+}
+
+// languageMatches compares language codes case-insensitively. The catalog
+// uses both "eng" and regional codes such as "en-US" and "en-GB" for English,
+// so "eng" matches all of them.
+func languageMatches(filter, code string) bool {
+	filter, code = strings.ToLower(filter), strings.ToLower(code)
+	return filter == code || (filter == "eng" && strings.HasPrefix(code, "en-"))
+}
+
+// filterHits drops the hits whose document does not pass the filter
+func filterHits(hits []Hit, filter *Filter) []Hit {
+	if filter.Empty() {
+		return hits
+	}
+
+	filtered := hits[:0]
+	for _, hit := range hits {
+		if filter.Match(hit.Doc) {
+			filtered = append(filtered, hit)
+		}
+	}
+	return filtered
+}
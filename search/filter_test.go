@@ -0,0 +1,199 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func intRange(min, max int) *IntRange {
+	return &IntRange{Min: &min, Max: &max}
+}
+
+func ratingRange(min, max float64) *FloatRange {
+	return &FloatRange{Min: &min, Max: &max}
+}
+
+func TestFilterValidate(t *testing.T) {
+	min := 2000
+	tests := []struct {
+		filter  *Filter
+		wantErr bool
+	}{
+		{filter: nil},
+		{filter: &Filter{}},
+		{filter: &Filter{Type: "book", Year: intRange(1990, 2000), Rating: ratingRange(3.5, 5), Language: "eng", Pages: intRange(100, 300)}},
+		{filter: &Filter{Year: &IntRange{Min: &min}}},
+		{filter: &Filter{Year: intRange(2000, 2000)}},
+		{filter: &Filter{Language: "en-US"}},
+		{filter: &Filter{Type: "album"}, wantErr: true},
+		{filter: &Filter{Type: "Book"}, wantErr: true},
+		{filter: &Filter{Year: intRange(2010, 2000)}, wantErr: true},
+		{filter: &Filter{Year: intRange(-1, 2000)}, wantErr: true},
+		{filter: &Filter{Pages: intRange(300, 100)}, wantErr: true},
+		{filter: &Filter{Rating: ratingRange(8, 6)}, wantErr: true},
+		{filter: &Filter{Rating: ratingRange(0, 11)}, wantErr: true},
+		{filter: &Filter{Language: "english"}, wantErr: true},
+		{filter: &Filter{Language: "9780674842113"}, wantErr: true},
+		{filter: &Filter{Language: "en_US"}, wantErr: true},
+		{filter: &Filter{Type: "movie", Language: "eng"}, wantErr: true},
+		{filter: &Filter{Type: "movie", Pages: intRange(1, 2)}, wantErr: true},
+	}
+	for _, test := range tests {
+		if err := test.filter.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("Validate(%s) = %v, want error %v", describeFilter(test.filter), err, test.wantErr)
+		}
+	}
+}
+
+// filterDocs are documents on both sides of the bounds of filterCases
+var filterDocs = []*Document{
+	{ID: 1, Type: "book", Year: 1999, Rating: 4.5, NumPages: 320, LanguageCode: "eng"},
+	{ID: 2, Type: "book", Year: 2000, Rating: 3.5, NumPages: 100, LanguageCode: "en-US"},
+	{ID: 3, Type: "book", Year: 2005, Rating: 3.49, NumPages: 99, LanguageCode: "EN-GB"},
+	{ID: 4, Type: "book", Year: 2010, Rating: 4.01, NumPages: 500, LanguageCode: "enm"},
+	{ID: 5, Type: "book", Year: 2011, Rating: 2, NumPages: 250, LanguageCode: "spa"},
+	{ID: 6, Type: "movie", Year: 2000, Rating: 7.5},
+	{ID: 7, Type: "movie", Year: 2010, Rating: 3.5},
+	{ID: 8, Type: "movie", Year: 2011, Rating: 9},
+}
+
+var filterCases = []*Filter{
+	nil,
+	{Type: "book"},
+	{Type: "movie"},
+	{Year: intRange(2000, 2010)},
+	{Year: &IntRange{Max: new(int)}},
+	{Rating: ratingRange(3.5, 4.5)},
+	{Pages: intRange(100, 320)},
+	{Language: "eng"},
+	{Language: "ENG"},
+	{Language: "en-us"},
+	{Language: "spa"},
+	{Language: "en"},
+	{Type: "book", Year: intRange(2000, 2010), Language: "eng"},
+	{Type: "movie", Rating: ratingRange(7, 10)},
+}
+
+func TestFilterMatch(t *testing.T) {
+	want := []string{
+		"1 2 3 4 5 6 7 8",
+		"1 2 3 4 5",
+		"6 7 8",
+		"2 3 4 6 7",
+		"",
+		"1 2 4 7",
+		"1 2 5",
+		"1 2 3",
+		"1 2 3",
+		"2",
+		"5",
+		"",
+		"2 3",
+		"6 8",
+	}
+	for i, f := range filterCases {
+		var ids []string
+		for _, doc := range filterDocs {
+			if f.Match(doc) {
+				ids = append(ids, fmt.Sprint(doc.ID))
+			}
+		}
+		if got := strings.Join(ids, " "); got != want[i] {
+			t.Errorf("Match(%s) = [%s], want [%s]", describeFilter(f), got, want[i])
+		}
+	}
+}
+
+// TestFilterSQL checks that the WHERE conditions the MySQL backend adds for
+// a filter select the same documents as Match
+func TestFilterSQL(t *testing.T) {
+	for _, f := range filterCases {
+		for _, doc := range filterDocs {
+			sql, args, ok := bookFilterSQL(f)
+			if doc.Type == "movie" {
+				sql, args, ok = movieFilterSQL(f)
+			}
+			got := ok && evaluateFilterSQL(t, sql, args, doc)
+			if want := f.Match(doc); got != want {
+				t.Errorf("the SQL of %s selects %s %d: %v, Match: %v (%q %v)", describeFilter(f), doc.Type, doc.ID, got, want, sql, args)
+			}
+		}
+	}
+}
+
+// languageSQL is the language condition of bookFilterSQL
+const languageSQL = "(language_code = ? OR (? = 'eng' AND language_code LIKE 'en-%'))"
+
+// evaluateFilterSQL evaluates the conditions of bookFilterSQL and
+// movieFilterSQL on a document the way MySQL does, comparing strings case
+// insensitively
+func evaluateFilterSQL(t *testing.T, sql string, args []interface{}, doc *Document) bool {
+	t.Helper()
+	if sql == "" {
+		return true
+	}
+
+	columns := map[string]float64{
+		"CAST(SUBSTRING_INDEX(publication_date, '/', -1) AS UNSIGNED)": float64(doc.Year),
+		"CAST(average_rating AS DECIMAL(4,2))":                         doc.Rating,
+		"CAST(num_pages AS UNSIGNED)":                                  float64(doc.NumPages),
+		"CAST(year AS UNSIGNED)":                                       float64(doc.Year),
+		"CAST(rating AS DECIMAL(4,2))":                                 doc.Rating,
+	}
+	conditions := strings.Split(strings.ReplaceAll(strings.TrimPrefix(sql, " AND "), languageSQL, "language"), " AND ")
+	for _, condition := range conditions {
+		if condition == "language" {
+			code, language, lowered := strings.ToLower(doc.LanguageCode), args[0].(string), args[1].(string)
+			args = args[2:]
+			if !strings.EqualFold(code, language) && !(lowered == "eng" && strings.HasPrefix(code, "en-")) {
+				return false
+			}
+			continue
+		}
+
+		column, bound, isMin := strings.Cut(condition, " >= ?")
+		if !isMin {
+			column, bound, _ = strings.Cut(condition, " <= ?")
+		}
+		value, known := columns[column]
+		if !known || bound != "" {
+			t.Fatalf("unexpected condition %q", condition)
+		}
+		var arg float64
+		switch a := args[0].(type) {
+		case int:
+			arg = float64(a)
+		case float64:
+			arg = a
+		}
+		args = args[1:]
+		if (isMin && value < arg) || (!isMin && value > arg) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeFilter(f *Filter) string {
+	if f == nil {
+		return "nil"
+	}
+	s := fmt.Sprintf("type %q language %q", f.Type, f.Language)
+	for name, r := range map[string]*IntRange{"year": f.Year, "pages": f.Pages} {
+		if r != nil {
+			s += fmt.Sprintf(" %s %v-%v", name, deref(r.Min), deref(r.Max))
+		}
+	}
+	if f.Rating != nil {
+		s += fmt.Sprintf(" rating %v-%v", deref(f.Rating.Min), deref(f.Rating.Max))
+	}
+	return s
+}
+
+func deref[T any](p *T) interface{} {
+	if p == nil {
+		return "open"
+	}
+	return *p
+}
@@ -0,0 +1,163 @@
+package search
+
+import (
+	"anghami-exercise/editdistance"
+	"sort"
+)
+
+// fuzzyPrefixLength limits the deletes stored per term to its first runes,
+// which keeps the dictionary small while still catching most typos
+const fuzzyPrefixLength = 7
+
+// maxFuzzyCandidates caps the number of corrections tried per query term
+const maxFuzzyCandidates = 10
+
+// FuzzyMatch is a vocabulary term within a small edit distance of a query term
+type FuzzyMatch struct {
+	Term     string
+	Distance int
+}
+
+// FuzzyDictionary finds vocabulary terms close to a misspelled term using
+// SymSpell-style precomputed deletes: every term is stored under each string
+// obtained by deleting up to two runes from it, so candidates for a query term
+// are found by looking up the deletes of the query term.
+type FuzzyDictionary struct {
+	terms   []string
+	counts  []int
+	deletes map[string][]int32
+}
+
+// MaxTypos returns the edit distance allowed for a term, which grows with its
+// length: short terms must match exactly, long terms may have two typos
+func MaxTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// NewFuzzyDictionary builds the delete dictionary of the given terms, which map
+// to the number of documents they occur in
+func NewFuzzyDictionary(counts map[string]int) *FuzzyDictionary {
+	d := &FuzzyDictionary{
+		terms:   make([]string, 0, len(counts)),
+		deletes: make(map[string][]int32),
+	}
+	for term := range counts {
+		d.terms = append(d.terms, term)
+	}
+	sort.Strings(d.terms)
+
+	d.counts = make([]int, len(d.terms))
+	for i, term := range d.terms {
+		d.counts[i] = counts[term]
+	}
+
+	for i, term := range d.terms {
+		maxTypos := MaxTypos(term)
+		if maxTypos == 0 {
+			continue
+		}
+		for _, del := range deletesOf(prefixOf(term), maxTypos) {
+			d.deletes[del] = append(d.deletes[del], int32(i))
+		}
+	}
+	return d
+}
+
+// Contains reports whether term is in the dictionary
+func (d *FuzzyDictionary) Contains(term string) bool {
+	i := sort.SearchStrings(d.terms, term)
+	return i < len(d.terms) && d.terms[i] == term
+}
+
+// Lookup returns the terms within MaxTypos(term) edits of term, closest and
+// then most frequent first. The term itself is not returned.
+func (d *FuzzyDictionary) Lookup(term string) []FuzzyMatch {
+	maxTypos := MaxTypos(term)
+	if maxTypos == 0 || d == nil {
+		return nil
+	}
+
+	seen := make(map[int32]bool)
+	var ids []int32
+	distances := make(map[int32]int)
+	for _, del := range deletesOf(prefixOf(term), maxTypos) {
+		for _, id := range d.deletes[del] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			candidate := d.terms[id]
+			if candidate == term {
+				continue
+			}
+			if distance, ok := editdistance.Within(term, candidate, maxTypos); ok && distance <= MaxTypos(candidate) {
+				ids = append(ids, id)
+				distances[id] = distance
+			}
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if distances[a] != distances[b] {
+			return distances[a] < distances[b]
+		}
+		if d.counts[a] != d.counts[b] {
+			return d.counts[a] > d.counts[b]
+		}
+		return a < b
+	})
+	if len(ids) > maxFuzzyCandidates {
+		ids = ids[:maxFuzzyCandidates]
+	}
+
+	matches := make([]FuzzyMatch, len(ids))
+	for i, id := range ids {
+		matches[i] = FuzzyMatch{Term: d.terms[id], Distance: distances[id]}
+	}
+	return matches
+}
+
+// prefixOf returns the first fuzzyPrefixLength runes of term
+func prefixOf(term string) string {
+	runes := []rune(term)
+	if len(runes) > fuzzyPrefixLength {
+		runes = runes[:fuzzyPrefixLength]
+	}
+	return string(runes)
+}
+
+// deletesOf returns term and every distinct string obtained by deleting up to
+// maxDeletes runes from it
+func deletesOf(term string, maxDeletes int) []string {
+	seen := map[string]bool{term: true}
+	result := []string{term}
+	level := []string{term}
+	for n := 0; n < maxDeletes; n++ {
+		var next []string
+		for _, s := range level {
+			runes := []rune(s)
+			if len(runes) <= 1 {
+				continue
+			}
+			for i := range runes {
+				del := string(runes[:i]) + string(runes[i+1:])
+				if !seen[del] {
+					seen[del] = true
+					result = append(result, del)
+					next = append(next, del)
+				}
+			}
+		}
+		level = next
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"anghami-exercise/editdistance"
+	"testing"
+)
+
+func TestMaxTypos(t *testing.T) {
+	tests := []struct {
+		term string
+		want int
+	}{
+		{"", 0},
+		{"cat", 0},
+		{"dune", 1},
+		{"tolkien", 1},
+		{"sorcerer", 2},
+		{"machiavelli", 2},
+		{"prés", 1}, // runes, not bytes
+		{"كتاب", 1}, // four Arabic letters
+		{"pré", 0},  // four bytes
+	}
+	for _, test := range tests {
+		if got := MaxTypos(test.term); got != test.want {
+			t.Errorf("MaxTypos(%q) = %d, want %d", test.term, got, test.want)
+		}
+	}
+}
+
+func TestFuzzyDictionaryLookup(t *testing.T) {
+	d := NewFuzzyDictionary(map[string]int{
+		"potter": 5, "sorcerer": 3, "harry": 10, "cat": 1,
+	})
+
+	tests := []struct {
+		term string
+		want string // the closest match, or "" for none
+	}{
+		{"poter", "potter"},      // deletion
+		{"pottter", "potter"},    // insertion
+		{"hary", "harry"},        // deletion in a short term
+		{"sorcreer", "sorcerer"}, // transposition
+		{"sorcrer", "sorcerer"},  // deletion
+		{"pttr", ""},             // two typos in a short term
+		{"cta", ""},              // terms under four runes must match exactly
+		{"potter", ""},           // the term itself
+		{"dune", ""},
+	}
+	for _, test := range tests {
+		matches := d.Lookup(test.term)
+		if test.want == "" {
+			if len(matches) != 0 {
+				t.Errorf("Lookup(%q) = %v, want none", test.term, matches)
+			}
+			continue
+		}
+		if len(matches) == 0 || matches[0].Term != test.want {
+			t.Errorf("Lookup(%q) = %v, want %s first", test.term, matches, test.want)
+			continue
+		}
+		if want := editdistance.Distance(test.term, test.want); matches[0].Distance != want || want > MaxTypos(test.term) {
+			t.Errorf("Lookup(%q) distance = %d, want %d within %d", test.term, matches[0].Distance, want, MaxTypos(test.term))
+		}
+	}
+}
+
+func TestFuzzyDictionaryCandidateCap(t *testing.T) {
+	// Fifteen terms one insertion away from "testing", more frequent as
+	// their last letter comes later
+	counts := make(map[string]int)
+	for i, c := range "abcdefghijklmno" {
+		counts["testing"+string(c)] = i + 1
+	}
+	counts["testingzz"] = 100
+
+	matches := NewFuzzyDictionary(counts).Lookup("testing")
+	if len(matches) != maxFuzzyCandidates {
+		t.Fatalf("Lookup returned %d candidates, want %d", len(matches), maxFuzzyCandidates)
+	}
+	if matches[0].Term != "testingo" || matches[len(matches)-1].Term != "testingf" {
+		t.Errorf("Lookup = %v, want the most frequent from testingo to testingf", matches)
+	}
+	for _, match := range matches {
+		if match.Distance != 1 {
+			t.Errorf("Lookup returned %v, want only terms within one typo of a seven letter term", match)
+		}
+	}
+}
+
+func TestIndexSearchTypos(t *testing.T) {
+	ix := NewIndex([]*Document{
+		{ID: 1, Type: "book", Title: "The Hobbit", Authors: "J.R.R. Tolkien"},
+		{ID: 2, Type: "book", Title: "Harry Potter and the Half-Blood Prince", Authors: "J.K. Rowling"},
+	})
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		// A swapped pair of letters is a single typo
+		{"tolkein", 1},
+		{"hobibt", 1},
+		{"author:rowlnig", 2},
+		{"ptoter", 2},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		hits := ix.Search(expr)
+		if len(hits) != 1 || hits[0].Doc.ID != test.want {
+			t.Errorf("Search(%q) returned %d hits, want document %d", test.query, len(hits), test.want)
+		}
+	}
+}
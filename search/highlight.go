@@ -0,0 +1,206 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"html"
+	"slices"
+	"strings"
+)
+
+// snippetWords is the number of words kept around the best match in a snippet
+const snippetWords = 30
+
+// snippetContext is the number of words shown before the first match when
+// the snippet does not start at the beginning of the field
+const snippetContext = 5
+
+// SnippetFields are the long fields a snippet can be cropped from, in order
+// of preference
+var SnippetFields = []string{"summary", "short_summary"}
+
+// Matcher reports which document words matched an analyzed query: the
+// words searched for in a field, words starting with the last word, and the
+// typo corrections that were searched for. Excluded words never match, and
+// the words of a phrase only match where they are adjacent.
+type Matcher struct {
+	plan []clausePlan
+}
+
+// Matches reports which words of a field of the document matched a query
+// word. The words are analyzed with the field's chain in the document's
+// language, as they were when they were indexed, and a phrase matches where
+// its terms occur at the same distances as in the query, as in Index.Search.
+func (m *Matcher) Matches(doc *Document, field string, words []analysis.Word) []bool {
+	matched := make([]bool, len(words))
+	if m == nil {
+		return matched
+	}
+
+	analyzer := fieldAnalyzer(doc, field)
+	texts, terms := make([]string, len(words)), make([]string, len(words))
+	for i, w := range words {
+		texts[i], terms[i] = normalizer.Term(w.Text), analyzer.Term(w.Text)
+	}
+
+	for _, clause := range m.plan {
+		if clause.exclude {
+			continue
+		}
+		for _, operand := range clause.operands {
+			qts := operand.terms[field]
+			for start := range words {
+				found := len(qts) > 0
+				for _, qt := range qts {
+					i := start + qt.offset - qts[0].offset
+					if i >= len(words) || !qt.matches(terms[i], texts[i]) {
+						found = false
+						break
+					}
+				}
+				if !found {
+					continue
+				}
+				for _, qt := range qts {
+					matched[start+qt.offset-qts[0].offset] = true
+				}
+			}
+		}
+	}
+	return matched
+}
+
+// matches reports whether a document word, analyzed to term and normalized
+// to text, matches the query term exactly, as a prefix or with typos
+func (qt queryTerm) matches(term, text string) bool {
+	if (term != "" && slices.Contains(qt.forms, term)) || (qt.last && strings.HasPrefix(text, qt.text)) {
+		return true
+	}
+	_, ok := qt.fuzzy[term]
+	return ok
+}
+
+// Highlight is a matched word in a document field; Start and End are rune
+// offsets into the field value, End exclusive
+type Highlight struct {
+	Field string `json:"field"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Highlights returns the spans of every matched word in the searchable
+// fields of the document, in SearchableFields order
+func Highlights(doc *Document, m *Matcher) []Highlight {
+	highlights := []Highlight{}
+	if m == nil {
+		return highlights
+	}
+	for _, field := range SearchableFields {
+		words := analysis.Words(doc.FieldValue(field))
+		for i, matched := range m.Matches(doc, field, words) {
+			if matched {
+				highlights = append(highlights, Highlight{Field: field, Start: words[i].Start, End: words[i].End})
+			}
+		}
+	}
+	return highlights
+}
+
+// Snippet renders the part of the document's first non-empty SnippetFields
+// value with the most matched words, marking the matches in the given
+// format ("html" or "markdown"). It returns "" if the document has no such
+// field.
+func Snippet(doc *Document, m *Matcher, format string) string {
+	field, text := "", ""
+	for _, field = range SnippetFields {
+		if text = doc.FieldValue(field); text != "" {
+			break
+		}
+	}
+	ws := analysis.Words(text)
+	if len(ws) == 0 {
+		return ""
+	}
+
+	// Find the first window of snippetWords words with the most matches
+	matched := m.Matches(doc, field, ws)
+	counts := make([]int, len(ws)+1) // counts[i] is the number of matches before word i
+	for i := range ws {
+		counts[i+1] = counts[i]
+		if matched[i] {
+			counts[i+1]++
+		}
+	}
+	best, bestCount := 0, -1
+	for start := 0; start == 0 || start+snippetWords <= len(ws); start++ {
+		if count := counts[min(start+snippetWords, len(ws))] - counts[start]; count > bestCount {
+			best, bestCount = start, count
+		}
+	}
+
+	// Move the window so the first match has a few words of context, as
+	// long as the last match stays inside and the window stays full
+	first, last := -1, -1
+	for i := best; i < min(best+snippetWords, len(ws)); i++ {
+		if matched[i] {
+			last = i
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first >= 0 {
+		best = max(first-snippetContext, last-snippetWords+1, 0)
+		best = min(best, max(len(ws)-snippetWords, 0))
+	}
+	end := min(best+snippetWords, len(ws))
+
+	runes := []rune(text)
+	from, to := ws[best].Start, ws[end-1].End
+	if best == 0 {
+		from = 0
+	}
+	if end == len(ws) {
+		to = len(runes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for i := best; i < end; i++ {
+		if !matched[i] {
+			continue
+		}
+		b.WriteString(escapeSnippet(string(runes[pos:ws[i].Start]), format))
+		b.WriteString(markSnippet(escapeSnippet(string(runes[ws[i].Start:ws[i].End]), format), format))
+		pos = ws[i].End
+	}
+	b.WriteString(escapeSnippet(string(runes[pos:to]), format))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// ValidSnippetFormat reports whether format can be passed to Snippet
+func ValidSnippetFormat(format string) bool {
+	return format == "html" || format == "markdown"
+}
+
+// markdownEscaper escapes the characters that would change markdown emphasis
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `[`, `\[`, `]`, `\]`)
+
+func escapeSnippet(text, format string) string {
+	if format == "markdown" {
+		return markdownEscaper.Replace(text)
+	}
+	return html.EscapeString(text)
+}
+
+func markSnippet(text, format string) string {
+	if format == "markdown" {
+		return "**" + text + "**"
+	}
+	return "<em>" + text + "</em>"
+}
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// matcherFor plans the query against the documents like Index.Search
+func matcherFor(t *testing.T, docs []*Document, query string) *Matcher {
+	t.Helper()
+	expr, err := ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Matcher{plan: NewIndex(docs).stats.planQuery(expr)}
+}
+
+// highlighted returns the highlighted words of the document's fields
+func highlighted(doc *Document, m *Matcher) []string {
+	var words []string
+	for _, h := range Highlights(doc, m) {
+		words = append(words, h.Field+":"+string([]rune(doc.FieldValue(h.Field))[h.Start:h.End]))
+	}
+	return words
+}
+
+func TestHighlights(t *testing.T) {
+	doc := &Document{
+		ID: 1, Type: "movie",
+		Title:    "The Prince and the Half-Blood Prince",
+		Director: "Harriet Potter",
+		Summary:  "A prince, half mad, meets a half-blood prince and a blood moon.",
+	}
+	docs := []*Document{doc, {ID: 2, Type: "movie", Title: "Spotter"}}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`"half-blood prince"`, []string{"title:Half", "title:Blood", "title:Prince", "summary:half", "summary:blood", "summary:prince"}},
+		{"half-blood", []string{"title:Half", "title:Blood", "summary:half", "summary:blood"}},
+		{"prince", []string{"title:Prince", "title:Prince", "summary:prince", "summary:prince"}},
+		{`"prince half"`, []string{"summary:prince", "summary:half"}},
+		{"director:harr", []string{"director:Harriet"}},
+		{"director:poter", []string{"director:Potter"}},
+		{"moon -prince", []string{"summary:moon"}},
+	}
+	for _, test := range tests {
+		if got := highlighted(doc, matcherFor(t, docs, test.query)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Highlights(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	if got := Highlights(doc, nil); len(got) != 0 {
+		t.Errorf("Highlights without a matcher = %v, want none", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	// A summary of 60 numbered words, with "match" as the word at the given
+	// position, and its snippet
+	snippet := func(positions ...int) string {
+		words := make([]string, 60)
+		for i := range words {
+			words[i] = fmt.Sprintf("w%d", i)
+		}
+		for _, p := range positions {
+			words[p] = "match"
+		}
+		doc := &Document{ID: 1, Type: "movie", Summary: strings.Join(words, " ")}
+		return Snippet(doc, matcherFor(t, []*Document{doc}, "match"), "markdown")
+	}
+	window := func(from, to int) string {
+		var words []string
+		for i := from; i < to; i++ {
+			words = append(words, fmt.Sprintf("w%d", i))
+		}
+		return strings.Join(words, " ")
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"no match", snippet(), window(0, snippetWords) + "…"},
+		{"first word", snippet(0), "**match** " + window(1, snippetWords) + "…"},
+		{"last word", snippet(59), "…" + window(30, 59) + " **match**"},
+		{"middle", snippet(20), "…" + window(15, 20) + " **match** " + window(21, 45) + "…"},
+		{"near the start", snippet(3), window(0, 3) + " **match** " + window(4, snippetWords) + "…"},
+		{"spread", snippet(10, 38), "…" + window(9, 10) + " **match** " + window(11, 38) + " **match**…"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: Snippet = %q, want %q", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestSnippetFormats(t *testing.T) {
+	doc := &Document{ID: 1, Type: "movie", ShortSummary: "Tom & Jerry *chase* <mice>"}
+	m := matcherFor(t, []*Document{doc}, "jerry")
+	if got, want := Snippet(doc, m, "html"), "Tom &amp; <em>Jerry</em> *chase* &lt;mice&gt;"; got != want {
+		t.Errorf("html Snippet = %q, want %q", got, want)
+	}
+	if got, want := Snippet(doc, m, "markdown"), `Tom & **Jerry** \*chase\* <mice>`; got != want {
+		t.Errorf("markdown Snippet = %q, want %q", got, want)
+	}
+	if got := Snippet(&Document{ID: 2, Type: "book", Title: "Jerry"}, m, "html"); got != "" {
+		t.Errorf("Snippet of a document without a summary = %q, want none", got)
+	}
+}
@@ -0,0 +1,104 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Identifier is a book ISBN or a movie IMDB ID found in a query. ISBNs are
+// kept as ISBN-13 without hyphens, so an ISBN-10 finds the same book.
+type Identifier struct {
+	Kind  string // "isbn" or "imdb"
+	Value string
+}
+
+// String returns the identifier as "kind:value"
+func (id Identifier) String() string {
+	return id.Kind + ":" + id.Value
+}
+
+var (
+	isbnPattern = regexp.MustCompile(`^[0-9][0-9 -]{8,15}[0-9Xx]$`)
+	imdbPattern = regexp.MustCompile(`^[tT]{2}[0-9]{7,8}$`)
+)
+
+// ParseIdentifier reports whether the whole query is an ISBN-10 or ISBN-13
+// with a valid checksum, hyphens and spaces allowed, or an IMDB ID such as
+// "tt7026230"
+func ParseIdentifier(query string) (Identifier, bool) {
+	query = strings.TrimSpace(query)
+	if imdbPattern.MatchString(query) {
+		return Identifier{Kind: "imdb", Value: strings.ToLower(query)}, true
+	}
+	if !isbnPattern.MatchString(query) {
+		return Identifier{}, false
+	}
+	if isbn, ok := normalizeISBN(query); ok {
+		return Identifier{Kind: "isbn", Value: isbn}, true
+	}
+	return Identifier{}, false
+}
+
+// normalizeISBN validates an ISBN-10 or ISBN-13 and returns it as an
+// ISBN-13 without separators
+func normalizeISBN(s string) (string, bool) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+	switch len(digits) {
+	case 10:
+		// The weighted sum, 10 for the first digit down to 1 for the check
+		// digit, must be divisible by 11. The check digit X stands for 10.
+		sum := 0
+		for i, c := range digits {
+			value := int(c - '0')
+			if c == 'X' && i == 9 {
+				value = 10
+			} else if c < '0' || c > '9' {
+				return "", false
+			}
+			sum += (10 - i) * value
+		}
+		if sum%11 != 0 {
+			return "", false
+		}
+		isbn := "978" + digits[:9]
+		return isbn + string(isbn13CheckDigit(isbn)), true
+
+	case 13:
+		if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+			return "", false
+		}
+		if strings.ContainsRune(digits, 'X') || isbn13CheckDigit(digits[:12]) != digits[12] {
+			return "", false
+		}
+		return digits, true
+	}
+	return "", false
+}
+
+// isbn13CheckDigit computes the check digit of the first 12 digits of an
+// ISBN-13, which are weighted 1 and 3 alternately
+func isbn13CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(digits[i]-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// identifiers returns the identifiers a document can be looked up by
+func (d *Document) identifiers() []Identifier {
+	var ids []Identifier
+	for _, isbn := range []string{d.ISBN, d.ISBN13} {
+		if normalized, ok := normalizeISBN(isbn); ok {
+			ids = append(ids, Identifier{Kind: "isbn", Value: normalized})
+		}
+	}
+	if imdbPattern.MatchString(d.IMDBID) {
+		ids = append(ids, Identifier{Kind: "imdb", Value: strings.ToLower(d.IMDBID)})
+	}
+	return ids
+}
@@ -0,0 +1,44 @@
+package search
+
+import "testing"
+
+func TestParseIdentifier(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Identifier
+		ok    bool
+	}{
+		{"9780439785969", Identifier{"isbn", "9780439785969"}, true},
+		{"978-0-439-78596-9", Identifier{"isbn", "9780439785969"}, true},
+		{" 0439785960 ", Identifier{"isbn", "9780439785969"}, true},
+		{"0-439-78596-0", Identifier{"isbn", "9780439785969"}, true},
+		{"080442957X", Identifier{"isbn", "9780804429573"}, true},
+		{"tt7026230", Identifier{"imdb", "tt7026230"}, true},
+		{"TT7026230", Identifier{"imdb", "tt7026230"}, true},
+		{"9780439785968", Identifier{}, false}, // bad checksum
+		{"0439785961", Identifier{}, false},    // bad checksum
+		{"1234567890123", Identifier{}, false}, // not a 978/979 prefix
+		{"2017", Identifier{}, false},
+		{"tt70", Identifier{}, false},
+		{"harry potter", Identifier{}, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseIdentifier(test.query)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ParseIdentifier(%q) = %v, %v, want %v, %v", test.query, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestIdentifierIndex(t *testing.T) {
+	book := &Document{ID: 1, Type: "book", Title: "Harry Potter and the Half-Blood Prince", ISBN: "0439785960", ISBN13: "9780439785969"}
+	movie := &Document{ID: 2, Type: "movie", Title: "Patton Oswalt: Annihilation", IMDBID: "tt7026230"}
+	index := *identifierIndex([]*Document{book, movie})
+
+	for query, want := range map[string]*Document{"0-439-78596-0": book, "9780439785969": book, "tt7026230": movie} {
+		id, _ := ParseIdentifier(query)
+		if got := index[id]; got != want {
+			t.Errorf("lookup of %q = %v, want %v", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Posting records every position at which a term occurs in one document
+type Posting struct {
+	Doc       int
+	Positions []int
+}
+
+// fieldIndex is the inverted index of a single document field
+type fieldIndex struct {
+	postings map[string][]Posting
+	lengths  []int // number of terms per document
+}
+
+// Index is an immutable in-memory inverted index over catalog documents.
+// A new Index is built whenever the catalog changes, so it is safe to
+// share between concurrent readers.
+type Index struct {
+	docs   []*Document
+	fields map[string]*fieldIndex
+	stats  *CorpusStats
+}
+
+// FieldWeights scales the BM25 score of each field, so that a match in a
+// title counts more than a match in a plot summary. Fields without a weight
+// count once.
+var FieldWeights = map[string]float64{
+	"title":         3,
+	"authors":       2,
+	"director":      2,
+	"writers":       1.5,
+	"cast":          1.5,
+	"publisher":     1,
+	"short_summary": 0.5,
+	"summary":       0.5,
+}
+
+// fieldWeight returns the weight of a field
+func fieldWeight(field string) float64 {
+	if w, ok := FieldWeights[field]; ok {
+		return w
+	}
+	return 1
+}
+
+// ParseFieldWeights parses weights written as "field=weight,field=weight"
+// (for example "title=3,cast=1")
+func ParseFieldWeights(s string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		field, value, ok := strings.Cut(entry, "=")
+		field = strings.TrimSpace(field)
+		if !ok || !isSearchableField(field) {
+			return nil, fmt.Errorf("invalid field weight %q, expected one of %s", entry, strings.Join(SearchableFields, ", "))
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight in %q", entry)
+		}
+		weights[field] = weight
+	}
+	return weights, nil
+}
+
+// NewIndex builds an inverted index over the given documents
+func NewIndex(docs []*Document) *Index {
+	return newIndex(docs, NewCorpusStats(docs))
+}
+
+// newIndex builds an inverted index over the documents that scores matches
+// with the given statistics
+func newIndex(docs []*Document, stats *CorpusStats) *Index {
+	ix := &Index{
+		docs:   docs,
+		fields: make(map[string]*fieldIndex),
+		stats:  stats,
+	}
+
+	for _, field := range SearchableFields {
+		fi := &fieldIndex{
+			postings: make(map[string][]Posting),
+			lengths:  make([]int, len(docs)),
+		}
+		for docID, doc := range docs {
+			tokens := analyzeField(doc, field)
+			fi.lengths[docID] = len(tokens)
+
+			positions := make(map[string][]int)
+			for _, token := range tokens {
+				positions[token.Term] = append(positions[token.Term], token.Position)
+			}
+			for term, p := range positions {
+				fi.postings[term] = append(fi.postings[term], Posting{Doc: docID, Positions: p})
+			}
+		}
+
+		ix.fields[field] = fi
+	}
+
+	return ix
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Postings returns the posting list of a term in a field
+func (ix *Index) Postings(field, term string) []Posting {
+	fi, ok := ix.fields[field]
+	if !ok {
+		return nil
+	}
+	return fi.postings[term]
+}
+
+// Stats returns the corpus statistics of the indexed documents
+func (ix *Index) Stats() *CorpusStats {
+	return ix.stats
+}
+
+// docMatch accumulates the score, matched fields and typos of one document
+type docMatch struct {
+	score  float64
+	fields map[string]bool
+	typos  int
+}
+
+// Search returns the documents matching the parsed query. Words match in
+// any of their operand's fields, either exactly, as a prefix for the last
+// word, or within a few typos; the words of a phrase must also be adjacent.
+// Scores are the sum of the weighted per-field BM25 scores.
+func (ix *Index) Search(expr *Expr) []Hit {
+	plan := ix.stats.planQuery(expr)
+	matches := ix.evaluate(plan)
+
+	// Return documents in index order so results are deterministic
+	ids := make([]int, 0, len(matches))
+	for doc := range matches {
+		ids = append(ids, doc)
+	}
+	sort.Ints(ids)
+
+	matcher := &Matcher{plan: plan}
+	hits := make([]Hit, len(ids))
+	for i, id := range ids {
+		hits[i] = Hit{
+			Doc:           ix.docs[id],
+			Score:         matches[id].score,
+			MatchedFields: sortedFields(matches[id].fields),
+			Typos:         matches[id].typos,
+			Matcher:       matcher,
+		}
+	}
+	return hits
+}
+
+// evaluate returns the documents matching every clause of the plan that is
+// not excluded and none of the excluded ones
+func (ix *Index) evaluate(plan []clausePlan) map[int]*docMatch {
+	var matches map[int]*docMatch
+	var excluded []map[int]*docMatch
+	for _, clause := range plan {
+		// A document matches a clause when it matches one of its operands,
+		// and scores the sum of the operands it matches
+		clauseMatches := make(map[int]*docMatch)
+		for _, operand := range clause.operands {
+			for doc, om := range ix.matchOperand(operand) {
+				m, ok := clauseMatches[doc]
+				if !ok {
+					clauseMatches[doc] = om
+					continue
+				}
+				m.score += om.score
+				m.typos = min(m.typos, om.typos)
+				for field := range om.fields {
+					m.fields[field] = true
+				}
+			}
+		}
+
+		if clause.exclude {
+			excluded = append(excluded, clauseMatches)
+			continue
+		}
+
+		// Every clause must match, so keep only documents seen for all of them
+		if matches == nil {
+			matches = clauseMatches
+			continue
+		}
+		for doc, m := range matches {
+			cm, ok := clauseMatches[doc]
+			if !ok {
+				delete(matches, doc)
+				continue
+			}
+			m.score += cm.score
+			m.typos += cm.typos
+			for field := range cm.fields {
+				m.fields[field] = true
+			}
+		}
+	}
+
+	for _, clauseMatches := range excluded {
+		for doc := range clauseMatches {
+			delete(matches, doc)
+		}
+	}
+	return matches
+}
+
+// termOccurrence is a document term matching a query term at one position
+type termOccurrence struct {
+	score float64
+	typos int
+}
+
+// matchOperand returns the documents matching an operand in at least one of
+// its fields. A phrase matches where its terms occur at the same distances
+// as in the query, and scores the sum of its terms' scores at the best
+// occurrence. Words the field's analysis drops, such as stopwords, are
+// skipped but still count for the distances.
+func (ix *Index) matchOperand(operand operandPlan) map[int]*docMatch {
+	matches := make(map[int]*docMatch)
+	for _, field := range operand.fields {
+		fi := ix.fields[field]
+		terms := operand.terms[field]
+		if len(terms) == 0 {
+			continue
+		}
+
+		// occurrences[i][doc][pos] is the best match of term i at pos,
+		// restricted to documents that matched every previous term
+		occurrences := make([]map[int]map[int]termOccurrence, len(terms))
+		for i, qt := range terms {
+			occurrences[i] = make(map[int]map[int]termOccurrence)
+			for _, term := range ix.stats.candidates(field, qt) {
+				for _, p := range fi.postings[term] {
+					if i > 0 && occurrences[i-1][p.Doc] == nil {
+						continue
+					}
+					score, typos, ok := ix.stats.matchScore(field, qt, term, len(p.Positions), fi.lengths[p.Doc])
+					if !ok {
+						continue
+					}
+					positions := occurrences[i][p.Doc]
+					if positions == nil {
+						positions = make(map[int]termOccurrence)
+						occurrences[i][p.Doc] = positions
+					}
+					// Positions only matter within phrases
+					docPositions := p.Positions
+					if len(terms) == 1 {
+						docPositions = []int{0}
+					}
+					for _, pos := range docPositions {
+						if o, seen := positions[pos]; !seen || score > o.score {
+							positions[pos] = termOccurrence{score: score, typos: typos}
+						}
+					}
+				}
+			}
+		}
+
+		last := len(terms) - 1
+		for doc := range occurrences[last] {
+			best, found := termOccurrence{}, false
+			for start, first := range occurrences[0][doc] {
+				o := first
+				for i := 1; i <= last && o.score >= 0; i++ {
+					next, ok := occurrences[i][doc][start+terms[i].offset-terms[0].offset]
+					if !ok {
+						o.score = -1
+						break
+					}
+					o.score += next.score
+					o.typos += next.typos
+				}
+				if o.score >= 0 && (!found || o.score > best.score) {
+					best, found = o, true
+				}
+			}
+			if !found {
+				continue
+			}
+
+			m, ok := matches[doc]
+			if !ok {
+				m = &docMatch{fields: make(map[string]bool), typos: best.typos}
+				matches[doc] = m
+			}
+			m.score += fieldWeight(field) * best.score
+			m.fields[field] = true
+			m.typos = min(m.typos, best.typos)
+		}
+	}
+	return matches
+}
+
+// sortedFields returns the fields in SearchableFields order
+func sortedFields(fields map[string]bool) []string {
+	var sorted []string
+	for _, field := range SearchableFields {
+		if fields[field] {
+			sorted = append(sorted, field)
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,128 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex([]*Document{
+		{ID: 1, Type: "book", Title: "The Lord of the Rings", Authors: "J.R.R. Tolkien"},
+		{ID: 2, Type: "book", Title: "The Rings of Saturn", Authors: "W.G. Sebald"},
+		{ID: 3, Type: "movie", Title: "Lord of War", Director: "Andrew Niccol"},
+		{ID: 4, Type: "movie", Title: "The Ring", Director: "Gore Verbinski"},
+		{ID: 5, Type: "book", Title: "Saturn Run", Authors: "John Sandford"},
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"word", "saturn", []int{2, 5}},
+		{"words", "lord rings", []int{1}},
+		{"phrase", `"lord of the rings"`, []int{1}},
+		{"phrase out of order", `"rings lord"`, nil},
+		{"phrase not adjacent", `"lord rings"`, nil},
+		{"phrase in a field", `author:"john sandford"`, []int{5}},
+		{"or", "tolkien OR sebald", []int{1, 2}},
+		{"or with a word", "saturn OR war lord", []int{3}},
+		{"or of fields", "author:sandford OR director:verbinski", []int{4, 5}},
+		{"excluded", "rings -tolkien", []int{2, 4}},
+		{"excluded phrase", `lord -"lord of war"`, []int{1}},
+		{"excluded field", "saturn -title:run", []int{2}},
+		{"no match", "dune", nil},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%s: ParseQuery(%q): %v", test.name, test.query, err)
+		}
+		var got []int
+		for _, hit := range ix.Search(expr) {
+			got = append(got, hit.Doc.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Search(%q) = %v, want %v", test.name, test.query, got, test.want)
+		}
+	}
+}
+
+func TestIndexPostings(t *testing.T) {
+	ix := NewIndex([]*Document{
+		{ID: 1, Type: "book", Title: "Tomorrow and Tomorrow and Tomorrow"},
+		{ID: 2, Type: "movie", Title: "Edge of Tomorrow"},
+	})
+
+	want := []Posting{{Doc: 0, Positions: []int{0, 2, 4}}, {Doc: 1, Positions: []int{2}}}
+	if got := ix.Postings("title", "tomorrow"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Postings(title, tomorrow) = %v, want %v", got, want)
+	}
+	if got := ix.Postings("cast", "tomorrow"); got != nil {
+		t.Errorf("Postings(cast, tomorrow) = %v, want none", got)
+	}
+	if got := ix.Postings("missing", "tomorrow"); got != nil {
+		t.Errorf("Postings(missing, tomorrow) = %v, want none", got)
+	}
+}
+
+func TestMemoryBackendIndexDelete(t *testing.T) {
+	b := NewMemoryBackend()
+	search := func(query string) []int {
+		t.Helper()
+		hits, err := b.Search(Query{Text: query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, hit := range hits {
+			ids = append(ids, hit.Doc.ID)
+		}
+		return ids
+	}
+
+	b.Index([]*Document{
+		{ID: 1, Type: "book", Title: "Dune"},
+		{ID: 2, Type: "book", Title: "Dune Messiah"},
+		{ID: 1, Type: "movie", Title: "Dune"},
+	})
+	if got := search("dune"); !reflect.DeepEqual(got, []int{1, 2, 1}) {
+		t.Fatalf("Search(dune) = %v, want the two books and the movie", got)
+	}
+
+	// Reindexing a key replaces the document and its postings
+	b.Index([]*Document{{ID: 2, Type: "book", Title: "Children of Dune"}})
+	if got := search("messiah"); got != nil {
+		t.Errorf("Search(messiah) = %v after the title changed, want none", got)
+	}
+	if got := search("children"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Search(children) = %v, want [2]", got)
+	}
+
+	// Deleting a book keeps the movie with the same ID
+	b.Delete("book-1")
+	ix := b.index.Load()
+	if ix.Len() != 2 {
+		t.Errorf("Len() = %d after deleting, want 2", ix.Len())
+	}
+	for _, p := range ix.Postings("title", "dune") {
+		if p.Doc >= ix.Len() {
+			t.Errorf("posting %v points past the %d documents", p, ix.Len())
+		}
+	}
+	if got := len(ix.Postings("title", "dune")); got != 2 {
+		t.Errorf("dune has %d postings after deleting, want 2", got)
+	}
+	if got := search("dune"); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("Search(dune) = %v after deleting book-1, want the other book and the movie", got)
+	}
+	if stats, _ := b.Stats(); stats.Documents != 2 {
+		t.Errorf("Stats().Documents = %d, want 2", stats.Documents)
+	}
+
+	// A sync removes and indexes documents in one step
+	b.Replace([]*Document{{ID: 3, Type: "book", Title: "Dune Messiah"}}, []string{"book-2"})
+	if got := search("dune"); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("Search(dune) = %v after replacing book-2 by book-3, want [3 1]", got)
+	}
+}
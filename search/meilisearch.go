@@ -0,0 +1,342 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// meiliIndexName is the Meilisearch index that holds the catalog
+const meiliIndexName = "catalog"
+
+// meiliPageSize is the number of hits requested from Meilisearch at a time.
+// Every page is fetched, so totals and facets count all the matches.
+const meiliPageSize = 1000
+
+// meiliMaxTotalHits raises the number of hits Meilisearch lets a search page
+// through from its default of 1000 to more than the catalog holds
+const meiliMaxTotalHits = 1000000
+
+// meiliTaskTimeout bounds how long a change waits for Meilisearch to apply it
+const meiliTaskTimeout = 5 * time.Minute
+
+// meiliTaskPollInterval is the time between two checks of a pending task
+const meiliTaskPollInterval = 100 * time.Millisecond
+
+// meiliFilterableAttributes are the document fields filters are pushed down to
+var meiliFilterableAttributes = []string{"type", "year", "rating", "language_code", "num_pages"}
+
+// MeilisearchBackend answers queries through the Meilisearch HTTP API.
+// Meilisearch indexes asynchronously, so Index and Delete wait for it to
+// process their task, and the catalog change that triggered them is only
+// published once searches see it.
+type MeilisearchBackend struct {
+	host   string
+	key    string
+	index  string
+	client *http.Client
+	corpus *corpusTracker
+
+	settingsMu sync.Mutex
+	configured bool // whether the filterable attributes have been set
+}
+
+// meiliDocument is a Document stored in Meilisearch under its unique key
+type meiliDocument struct {
+	Key string `json:"key"`
+	*Document
+}
+
+// NewMeilisearchBackend creates a backend for the Meilisearch server at host
+func NewMeilisearchBackend(host, key, index string) *MeilisearchBackend {
+	return &MeilisearchBackend{
+		host:   strings.TrimRight(host, "/"),
+		key:    key,
+		index:  index,
+		client: &http.Client{Timeout: 10 * time.Second},
+		corpus: newCorpusTracker(),
+	}
+}
+
+// Search sends the query to the Meilisearch search endpoint, once per
+// combination of OR alternatives, and matches and scores the hits with BM25
+// so they rank consistently with the other backends
+func (b *MeilisearchBackend) Search(query Query) ([]Hit, error) {
+	expr, err := query.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := b.corpus.stats.Load()
+	plan := stats.planQuery(expr)
+
+	seen := make(map[string]bool)
+	var hits []Hit
+	for _, q := range meiliQueries(expr) {
+		for offset := 0; ; offset += meiliPageSize {
+			request := map[string]interface{}{
+				"q":      q,
+				"offset": offset,
+				"limit":  meiliPageSize,
+			}
+			if filter := meiliFilter(query.Filter); filter != "" {
+				request["filter"] = filter
+			}
+
+			var response struct {
+				Hits []meiliDocument `json:"hits"`
+			}
+			if err := b.do(http.MethodPost, "/indexes/"+url.PathEscape(b.index)+"/search", request, &response); err != nil {
+				return nil, err
+			}
+
+			for _, doc := range response.Hits {
+				if doc.Document == nil || seen[doc.Key] {
+					continue
+				}
+				seen[doc.Key] = true
+				if hit, ok := stats.scoreDocument(doc.Document, plan); ok {
+					hits = append(hits, hit)
+				}
+			}
+			if len(response.Hits) < meiliPageSize {
+				break
+			}
+		}
+	}
+
+	// Language aliases such as "en-US" for "eng" cannot be expressed in a
+	// Meilisearch filter, so the filter is always applied again here
+	return filterHits(hits, query.Filter), nil
+}
+
+// maxMeiliQueries caps the number of Meilisearch searches run for one query
+const maxMeiliQueries = 16
+
+// meiliQueries expands the OR alternatives of a query into the Meilisearch
+// queries that together find every match. Phrases are quoted, and exclusions
+// and field scopes are left to the BM25 matching. A clause whose alternatives
+// would take the searches past maxMeiliQueries is left out of them instead,
+// so they find more documents than match, and left to the BM25 matching too.
+func meiliQueries(expr *Expr) []string {
+	if len(expr.Clauses) == 0 {
+		return nil
+	}
+
+	queries := [][]string{nil}
+	for _, clause := range expr.positive() {
+		if len(queries)*len(clause.Operands) > maxMeiliQueries {
+			continue
+		}
+
+		var expanded [][]string
+		for _, q := range queries {
+			for _, op := range clause.Operands {
+				text := strings.Join(op.Words, " ")
+				if len(op.Words) > 1 {
+					text = `"` + text + `"`
+				}
+				expanded = append(expanded, append(q[:len(q):len(q)], text))
+			}
+		}
+		queries = expanded
+	}
+
+	result := make([]string, len(queries))
+	for i, q := range queries {
+		result[i] = strings.Join(q, " ")
+	}
+	return result
+}
+
+// meiliFilter translates the filter into a Meilisearch filter expression.
+// The language is left to filterHits.
+func meiliFilter(f *Filter) string {
+	if f.Empty() {
+		return ""
+	}
+
+	var conditions []string
+	switch {
+	case f.Type != "":
+		conditions = append(conditions, fmt.Sprintf("type = %q", f.Type))
+	case f.Language != "" || f.Pages != nil:
+		conditions = append(conditions, `type = "book"`)
+	}
+	if f.Year != nil && f.Year.Min != nil {
+		conditions = append(conditions, fmt.Sprintf("year >= %d", *f.Year.Min))
+	}
+	if f.Year != nil && f.Year.Max != nil {
+		conditions = append(conditions, fmt.Sprintf("year <= %d", *f.Year.Max))
+	}
+	if f.Rating != nil && f.Rating.Min != nil {
+		conditions = append(conditions, fmt.Sprintf("rating >= %g", *f.Rating.Min))
+	}
+	if f.Rating != nil && f.Rating.Max != nil {
+		conditions = append(conditions, fmt.Sprintf("rating <= %g", *f.Rating.Max))
+	}
+	if f.Pages != nil && f.Pages.Min != nil {
+		conditions = append(conditions, fmt.Sprintf("num_pages >= %d", *f.Pages.Min))
+	}
+	if f.Pages != nil && f.Pages.Max != nil {
+		conditions = append(conditions, fmt.Sprintf("num_pages <= %d", *f.Pages.Max))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// Index adds or replaces the documents in the Meilisearch index
+func (b *MeilisearchBackend) Index(docs []*Document) error {
+	return b.Replace(docs, nil)
+}
+
+// Replace removes the documents with the removed keys from the Meilisearch
+// index, adds or replaces docs, and updates the BM25 statistics once
+func (b *MeilisearchBackend) Replace(docs []*Document, removed []string) error {
+	if len(docs) == 0 && len(removed) == 0 {
+		return nil
+	}
+	if len(removed) > 0 {
+		if err := b.change(http.MethodPost, "/indexes/"+url.PathEscape(b.index)+"/documents/delete-batch", removed); err != nil {
+			return err
+		}
+	}
+
+	if len(docs) > 0 {
+		if err := b.configure(); err != nil {
+			return err
+		}
+		payload := make([]meiliDocument, len(docs))
+		for i, doc := range docs {
+			payload[i] = meiliDocument{Key: doc.Key(), Document: doc}
+		}
+		if err := b.change(http.MethodPost, "/indexes/"+url.PathEscape(b.index)+"/documents?primaryKey=key", payload); err != nil {
+			return err
+		}
+	}
+
+	b.corpus.update(docs, removed)
+	return nil
+}
+
+// configure declares the filterable attributes the first time documents are
+// indexed, so that filters can be pushed down to Meilisearch, and lets
+// searches page through every hit
+func (b *MeilisearchBackend) configure() error {
+	b.settingsMu.Lock()
+	defer b.settingsMu.Unlock()
+
+	if b.configured {
+		return nil
+	}
+	if err := b.change(http.MethodPut, "/indexes/"+url.PathEscape(b.index)+"/settings/filterable-attributes", meiliFilterableAttributes); err != nil {
+		return err
+	}
+	pagination := map[string]int{"maxTotalHits": meiliMaxTotalHits}
+	if err := b.change(http.MethodPatch, "/indexes/"+url.PathEscape(b.index)+"/settings/pagination", pagination); err != nil {
+		return err
+	}
+	b.configured = true
+	return nil
+}
+
+// Delete removes the documents from the Meilisearch index
+func (b *MeilisearchBackend) Delete(keys ...string) error {
+	return b.Replace(nil, keys)
+}
+
+func (b *MeilisearchBackend) Name() string { return "meilisearch" }
+
+// Stats reads the document count from the Meilisearch index stats
+func (b *MeilisearchBackend) Stats() (Stats, error) {
+	var response struct {
+		NumberOfDocuments int `json:"numberOfDocuments"`
+	}
+	if err := b.do(http.MethodGet, "/indexes/"+url.PathEscape(b.index)+"/stats", nil, &response); err != nil {
+		return Stats{}, err
+	}
+
+	return Stats{
+		Backend:   b.Name(),
+		Documents: response.NumberOfDocuments,
+	}, nil
+}
+
+// change sends a request that Meilisearch queues as a task, and waits until
+// the task is processed
+func (b *MeilisearchBackend) change(method, path string, in interface{}) error {
+	var enqueued struct {
+		TaskUID int `json:"taskUid"`
+	}
+	if err := b.do(method, path, in, &enqueued); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(meiliTaskTimeout)
+	for {
+		var task struct {
+			Status string `json:"status"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := b.do(http.MethodGet, fmt.Sprintf("/tasks/%d", enqueued.TaskUID), nil, &task); err != nil {
+			return err
+		}
+		switch task.Status {
+		case "succeeded":
+			return nil
+		case "failed", "canceled":
+			if task.Error != nil {
+				return fmt.Errorf("meilisearch task %d %s: %s", enqueued.TaskUID, task.Status, task.Error.Message)
+			}
+			return fmt.Errorf("meilisearch task %d %s", enqueued.TaskUID, task.Status)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("meilisearch task %d is still %s after %v", enqueued.TaskUID, task.Status, meiliTaskTimeout)
+		}
+		time.Sleep(meiliTaskPollInterval)
+	}
+}
+
+// do sends a JSON request to Meilisearch and decodes the JSON response into out
+func (b *MeilisearchBackend) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, b.host+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.key != "" {
+		req.Header.Set("Authorization", "Bearer "+b.key)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling meilisearch: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("meilisearch returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
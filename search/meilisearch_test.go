@@ -0,0 +1,279 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMeilisearch is a minimal in-memory stand-in for the Meilisearch HTTP API.
+// Changes are queued as tasks and only applied when their task is looked up.
+type fakeMeilisearch struct {
+	mu         sync.Mutex
+	key        string
+	docs       map[string]meiliDocument
+	filterable []string
+	pagination map[string]int
+	tasks      []func() // pending changes, by task uid
+	failTasks  bool     // whether tasks fail instead of applying their change
+}
+
+func newFakeMeilisearch(t *testing.T, key string) (*fakeMeilisearch, *httptest.Server) {
+	fake := &fakeMeilisearch{key: key, docs: make(map[string]meiliDocument)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeMeilisearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+f.key {
+		http.Error(w, `{"code":"invalid_api_key"}`, http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// enqueue answers a change request with the uid of its task
+	enqueue := func(change func()) {
+		f.tasks = append(f.tasks, change)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"taskUid": len(f.tasks) - 1})
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/indexes/catalog/documents":
+		if r.URL.Query().Get("primaryKey") != "key" {
+			http.Error(w, "missing primary key", http.StatusBadRequest)
+			return
+		}
+		var docs []meiliDocument
+		if err := json.NewDecoder(r.Body).Decode(&docs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		enqueue(func() {
+			for _, doc := range docs {
+				f.docs[doc.Key] = doc
+			}
+		})
+
+	case r.Method == http.MethodPut && r.URL.Path == "/indexes/catalog/settings/filterable-attributes":
+		var filterable []string
+		if err := json.NewDecoder(r.Body).Decode(&filterable); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		enqueue(func() { f.filterable = filterable })
+
+	case r.Method == http.MethodPatch && r.URL.Path == "/indexes/catalog/settings/pagination":
+		var pagination map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&pagination); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		enqueue(func() { f.pagination = pagination })
+
+	case r.Method == http.MethodPost && r.URL.Path == "/indexes/catalog/documents/delete-batch":
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		enqueue(func() {
+			for _, key := range keys {
+				delete(f.docs, key)
+			}
+		})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/tasks/"):
+		uid, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/tasks/"))
+		if err != nil || uid < 0 || uid >= len(f.tasks) {
+			http.NotFound(w, r)
+			return
+		}
+		if f.failTasks {
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "failed", "error": map[string]string{"message": "index not accessible"}})
+			return
+		}
+		// Report the task as pending once, as Meilisearch applies changes later
+		if apply := f.tasks[uid]; apply != nil {
+			f.tasks[uid] = nil
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "processing"})
+			apply()
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "succeeded"})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/indexes/catalog/search":
+		var request struct {
+			Q      string `json:"q"`
+			Filter string `json:"filter"`
+			Offset int    `json:"offset"`
+			Limit  int    `json:"limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Filter != "" && len(f.filterable) == 0 {
+			http.Error(w, `{"code":"invalid_search_filter"}`, http.StatusBadRequest)
+			return
+		}
+		var keys []string
+		for key := range f.docs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		hits := []meiliDocument{}
+		for _, key := range keys {
+			doc := f.docs[key]
+			// Only type filters are understood by the fake
+			if strings.Contains(request.Filter, `type = "movie"`) && doc.Type != "movie" ||
+				strings.Contains(request.Filter, `type = "book"`) && doc.Type != "book" {
+				continue
+			}
+			if strings.Contains(strings.ToLower(doc.Title), strings.ToLower(request.Q)) {
+				hits = append(hits, doc)
+			}
+		}
+		// Hits past maxTotalHits, 1000 by default, cannot be paged to
+		maxTotalHits := 1000
+		if n, ok := f.pagination["maxTotalHits"]; ok {
+			maxTotalHits = n
+		}
+		hits = hits[:min(len(hits), maxTotalHits)]
+		hits = hits[min(len(hits), request.Offset):]
+		hits = hits[:min(len(hits), request.Limit)]
+		json.NewEncoder(w).Encode(map[string]interface{}{"hits": hits})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/indexes/catalog/stats":
+		json.NewEncoder(w).Encode(map[string]interface{}{"numberOfDocuments": len(f.docs)})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestMeilisearchBackend(t *testing.T) {
+	_, server := newFakeMeilisearch(t, "secret")
+	backend := NewMeilisearchBackend(server.URL+"/", "secret", meiliIndexName)
+
+	docs := []*Document{
+		{ID: 1, Type: "book", Title: "Harry Potter and the Half-Blood Prince", Rating: 4.57},
+		{ID: 1, Type: "movie", Title: "Patton Oswalt: Annihilation", Rating: 7.4},
+	}
+	if err := backend.Index(docs); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	hits, err := backend.Search(Query{Text: "harry"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Doc.Type != "book" || hits[0].Doc.ID != 1 || hits[0].Doc.Rating != 4.57 {
+		t.Fatalf("Search returned %+v, want the Harry Potter book", hits)
+	}
+
+	hits, err = backend.Search(Query{Text: "harry", Filter: &Filter{Type: "movie"}})
+	if err != nil {
+		t.Fatalf("Search with filter: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("Search for movies returned %+v, want no hits", hits)
+	}
+
+	stats, err := backend.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.Backend != "meilisearch" || stats.Documents != 2 {
+		t.Fatalf("Stats returned %+v, want 2 meilisearch documents", stats)
+	}
+
+	if err := backend.Delete("book-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	hits, err = backend.Search(Query{Text: "harry"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("Search after Delete returned %+v, want no hits", hits)
+	}
+}
+
+func TestMeilisearchBackendAllHits(t *testing.T) {
+	_, server := newFakeMeilisearch(t, "secret")
+	backend := NewMeilisearchBackend(server.URL, "secret", meiliIndexName)
+
+	var docs []*Document
+	for i := 0; i < 2*meiliPageSize+10; i++ {
+		docs = append(docs, &Document{ID: i, Type: "book", Title: fmt.Sprintf("Dune %d", i)})
+	}
+	if err := backend.Index(docs); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	hits, err := backend.Search(Query{Text: "dune"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != len(docs) {
+		t.Errorf("Search returned %d hits, want all %d", len(hits), len(docs))
+	}
+}
+
+func TestMeiliQueries(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"harry potter", []string{"harry potter"}},
+		{`hp OR "harry potter" prince`, []string{"hp prince", `"harry potter" prince`}},
+		{"a OR b c OR d -e", []string{"a c", "a d", "b c", "b d"}},
+		// Five clauses of two alternatives would take 32 searches, so the
+		// last one is left out
+		{"a OR b c OR d e OR f g OR h i OR j k", []string{
+			"a c e g k", "a c e h k", "a c f g k", "a c f h k", "a d e g k", "a d e h k", "a d f g k", "a d f h k",
+			"b c e g k", "b c e h k", "b c f g k", "b c f h k", "b d e g k", "b d e h k", "b d f g k", "b d f h k",
+		}},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := meiliQueries(expr); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("meiliQueries(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestMeilisearchBackendError(t *testing.T) {
+	_, server := newFakeMeilisearch(t, "secret")
+	backend := NewMeilisearchBackend(server.URL, "wrong", meiliIndexName)
+
+	_, err := backend.Search(Query{Text: "harry"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Search with a bad key returned %v, want a 403 error", err)
+	}
+}
+
+func TestMeilisearchBackendTaskFailure(t *testing.T) {
+	fake, server := newFakeMeilisearch(t, "secret")
+	fake.failTasks = true
+	backend := NewMeilisearchBackend(server.URL, "secret", meiliIndexName)
+
+	err := backend.Index([]*Document{{ID: 1, Type: "book", Title: "Dune"}})
+	if err == nil || !strings.Contains(err.Error(), "index not accessible") {
+		t.Fatalf("Index returned %v, want the error of the failed task", err)
+	}
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryBackend answers queries from an in-process inverted index
+type MemoryBackend struct {
+	mu          sync.Mutex // serializes writers
+	docs        map[string]*Document
+	index       atomic.Pointer[Index]
+	lastIndexed time.Time
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	b := &MemoryBackend{docs: make(map[string]*Document)}
+	b.index.Store(NewIndex(nil))
+	return b
+}
+
+// Search looks the query up in the current index and applies the filter
+func (b *MemoryBackend) Search(query Query) ([]Hit, error) {
+	expr, err := query.expression()
+	if err != nil {
+		return nil, err
+	}
+	return filterHits(b.index.Load().Search(expr), query.Filter), nil
+}
+
+// Index upserts the documents and swaps in a rebuilt index
+func (b *MemoryBackend) Index(docs []*Document) error {
+	return b.Replace(docs, nil)
+}
+
+// Delete removes the documents and swaps in a rebuilt index
+func (b *MemoryBackend) Delete(keys ...string) error {
+	return b.Replace(nil, keys)
+}
+
+// Replace removes and upserts the documents, and swaps in an index rebuilt once
+func (b *MemoryBackend) Replace(docs []*Document, removed []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range removed {
+		delete(b.docs, key)
+	}
+	for _, doc := range docs {
+		b.docs[doc.Key()] = doc
+	}
+	b.rebuild()
+	return nil
+}
+
+func (b *MemoryBackend) Name() string { return "memory" }
+
+// Stats reports the number of indexed documents
+func (b *MemoryBackend) Stats() (Stats, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Stats{
+		Backend:     b.Name(),
+		Documents:   len(b.docs),
+		LastIndexed: b.lastIndexed,
+	}, nil
+}
+
+// rebuild builds a new index from b.docs; the caller must hold b.mu
+func (b *MemoryBackend) rebuild() {
+	docs := make([]*Document, 0, len(b.docs))
+	for _, doc := range b.docs {
+		docs = append(docs, doc)
+	}
+
+	// Keep a stable document order so results are deterministic
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Type != docs[j].Type {
+			return docs[i].Type < docs[j].Type
+		}
+		return docs[i].ID < docs[j].ID
+	})
+
+	b.index.Store(NewIndex(docs))
+	b.lastIndexed = time.Now()
+}
@@ -0,0 +1,282 @@
+package search
+
+import (
+	"database/sql"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MySQLBackend answers queries with a LIKE scan over the books and movies tables
+type MySQLBackend struct {
+	db     *sql.DB
+	corpus *corpusTracker
+}
+
+// NewMySQLBackend creates a backend that queries the database directly
+func NewMySQLBackend(db *sql.DB) *MySQLBackend {
+	return &MySQLBackend{db: db, corpus: newCorpusTracker()}
+}
+
+// bookSearchColumns and movieSearchColumns map the searchable fields of
+// each table to their columns
+var (
+	bookSearchColumns = map[string]string{
+		"title":     "title",
+		"authors":   "authors",
+		"publisher": "publisher",
+	}
+	movieSearchColumns = map[string]string{
+		"title":         "title",
+		"director":      "director",
+		"writers":       "writers",
+		"cast":          "`cast`",
+		"summary":       "summary",
+		"short_summary": "short_summary",
+	}
+)
+
+// Search finds the rows in which every word of the query that is not
+// excluded, or one of its typo corrections, appears as a substring of a
+// column the word is scoped to. The rows are then matched and scored with
+// BM25 in Go, which also checks phrases and exclusions. The filter is pushed
+// down to the WHERE clause.
+func (b *MySQLBackend) Search(query Query) ([]Hit, error) {
+	expr, err := query.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := b.corpus.stats.Load()
+	plan := stats.planQuery(expr)
+	if len(plan) == 0 {
+		return nil, nil
+	}
+
+	var docs []*Document
+	if clauses, args, ok := bookFilterSQL(query.Filter); ok {
+		if where, likeArgs, ok := likePlan(stats, bookSearchColumns, plan); ok {
+			books, err := queryDocuments(b.db, "SELECT "+bookColumns+" FROM books WHERE "+where+clauses, scanBook, append(likeArgs, args...)...)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, books...)
+		}
+	}
+	if clauses, args, ok := movieFilterSQL(query.Filter); ok {
+		if where, likeArgs, ok := likePlan(stats, movieSearchColumns, plan); ok {
+			movies, err := queryDocuments(b.db, "SELECT "+movieColumns+" FROM movies WHERE "+where+clauses, scanMovie, append(likeArgs, args...)...)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, movies...)
+		}
+	}
+
+	hits := make([]Hit, 0, len(docs))
+	for _, doc := range docs {
+		if hit, ok := stats.scoreDocument(doc, plan); ok {
+			hits = append(hits, hit)
+		}
+	}
+
+	// Apply the filter again in Go so that language aliases behave exactly
+	// like in the other backends
+	return filterHits(hits, query.Filter), nil
+}
+
+// bookFilterSQL returns the extra WHERE conditions for books, or false when
+// the filter excludes every book
+func bookFilterSQL(f *Filter) (string, []interface{}, bool) {
+	if f.Empty() {
+		return "", nil, true
+	}
+	if f.Type == "movie" {
+		return "", nil, false
+	}
+
+	var clauses []string
+	var args []interface{}
+	clauses, args = rangeSQL(clauses, args, "CAST(SUBSTRING_INDEX(publication_date, '/', -1) AS UNSIGNED)", f.Year)
+	clauses, args = floatRangeSQL(clauses, args, "CAST(average_rating AS DECIMAL(4,2))", f.Rating)
+	clauses, args = rangeSQL(clauses, args, "CAST(num_pages AS UNSIGNED)", f.Pages)
+	if f.Language != "" {
+		clauses = append(clauses, "(language_code = ? OR (? = 'eng' AND language_code LIKE 'en-%'))")
+		args = append(args, f.Language, strings.ToLower(f.Language))
+	}
+
+	return joinConditions(clauses), args, true
+}
+
+// movieFilterSQL returns the extra WHERE conditions for movies, or false when
+// the filter excludes every movie
+func movieFilterSQL(f *Filter) (string, []interface{}, bool) {
+	if f.Empty() {
+		return "", nil, true
+	}
+	if f.Type == "book" || f.Language != "" || f.Pages != nil {
+		return "", nil, false
+	}
+
+	var clauses []string
+	var args []interface{}
+	clauses, args = rangeSQL(clauses, args, "CAST(year AS UNSIGNED)", f.Year)
+	clauses, args = floatRangeSQL(clauses, args, "CAST(rating AS DECIMAL(4,2))", f.Rating)
+
+	return joinConditions(clauses), args, true
+}
+
+func rangeSQL(clauses []string, args []interface{}, column string, r *IntRange) ([]string, []interface{}) {
+	if r != nil && r.Min != nil {
+		clauses = append(clauses, column+" >= ?")
+		args = append(args, *r.Min)
+	}
+	if r != nil && r.Max != nil {
+		clauses = append(clauses, column+" <= ?")
+		args = append(args, *r.Max)
+	}
+	return clauses, args
+}
+
+func floatRangeSQL(clauses []string, args []interface{}, column string, r *FloatRange) ([]string, []interface{}) {
+	if r != nil && r.Min != nil {
+		clauses = append(clauses, column+" >= ?")
+		args = append(args, *r.Min)
+	}
+	if r != nil && r.Max != nil {
+		clauses = append(clauses, column+" <= ?")
+		args = append(args, *r.Max)
+	}
+	return clauses, args
+}
+
+// joinConditions prefixes every condition with AND
+func joinConditions(clauses []string) string {
+	if len(clauses) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(clauses, " AND ")
+}
+
+// likePlan builds a WHERE clause requiring every clause of the plan that is
+// not excluded to have an operand whose words, or one of their alternatives,
+// all appear in one of its columns. ok is false when no row of the table can
+// match, because a clause only has operands scoped to other tables' fields.
+func likePlan(stats *CorpusStats, columns map[string]string, plan []clausePlan) (string, []interface{}, bool) {
+	var clauses []string
+	var args []interface{}
+	for _, clause := range plan {
+		if clause.exclude {
+			continue
+		}
+
+		var operands []string
+		for _, operand := range clause.operands {
+			var operandColumns []string
+			for _, field := range operand.fields {
+				if column, ok := columns[field]; ok {
+					operandColumns = append(operandColumns, column)
+				}
+			}
+			if len(operandColumns) == 0 {
+				continue
+			}
+
+			var terms []string
+			for i, word := range operand.words {
+				var likes []string
+				for _, alternative := range stats.likeAlternatives(operand, i, word) {
+					for _, column := range operandColumns {
+						likes = append(likes, column+" LIKE ?")
+						args = append(args, "%"+likeEscaper.Replace(alternative)+"%")
+					}
+				}
+				terms = append(terms, "("+strings.Join(likes, " OR ")+")")
+			}
+			operands = append(operands, "("+strings.Join(terms, " AND ")+")")
+		}
+		if len(operands) == 0 {
+			return "", nil, false
+		}
+		clauses = append(clauses, "("+strings.Join(operands, " OR ")+")")
+	}
+	return strings.Join(clauses, " AND "), args, true
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeAlternatives returns the strings a column must contain to match the
+// word at offset i of the operand: the word itself, which also covers the
+// words it is a prefix of, and the words of the catalog that the field's
+// analysis turns into one of its forms or typo corrections, like "story"
+// and "stories" for "stori"
+func (s *CorpusStats) likeAlternatives(operand operandPlan, i int, word queryTerm) []string {
+	alternatives := []string{word.text}
+	for _, field := range operand.fields {
+		for _, qt := range operand.terms[field] {
+			if qt.offset != i {
+				continue
+			}
+			for _, term := range append(append([]string(nil), qt.forms...), sortedKeys(qt.fuzzy)...) {
+				for _, w := range s.words[field][term] {
+					if !slices.Contains(alternatives, w) {
+						alternatives = append(alternatives, w)
+					}
+				}
+			}
+		}
+	}
+	return alternatives
+}
+
+// sortedKeys returns the keys of a map in order, so that generated SQL is
+// deterministic
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Index only updates the BM25 statistics because the tables themselves are
+// the index
+func (b *MySQLBackend) Index(docs []*Document) error {
+	b.corpus.update(docs, nil)
+	return nil
+}
+
+// Delete only drops the documents from the BM25 statistics. The tables are
+// the source data, and the documents are already gone from them when the
+// catalog deletes them.
+func (b *MySQLBackend) Delete(keys ...string) error {
+	b.corpus.update(nil, keys)
+	return nil
+}
+
+// Replace updates the BM25 statistics for a whole sync at once
+func (b *MySQLBackend) Replace(docs []*Document, removed []string) error {
+	b.corpus.update(docs, removed)
+	return nil
+}
+
+func (b *MySQLBackend) Name() string { return "mysql" }
+
+// Stats counts the rows in the books and movies tables
+func (b *MySQLBackend) Stats() (Stats, error) {
+	var count int
+	err := b.db.QueryRow(`SELECT (SELECT COUNT(*) FROM books) + (SELECT COUNT(*) FROM movies)`).Scan(&count)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	// The tables are always up to date, so report the current time
+	return Stats{
+		Backend:     b.Name(),
+		Documents:   count,
+		LastIndexed: time.Now(),
+	}, nil
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLikePlan(t *testing.T) {
+	stats := NewCorpusStats([]*Document{
+		{ID: 1, Type: "movie", Title: "A Bronx Tale", Summary: "The story of a boy and his movies"},
+		{ID: 2, Type: "movie", Title: "Toy Story", Summary: "Stories of toys, like a movie"},
+		{ID: 3, Type: "movie", Title: "Harry and the Hendersons", Summary: "Harrying Bigfoot"},
+	})
+	columns := map[string]string{"title": "title", "summary": "summary"}
+
+	tests := []struct {
+		query string
+		want  []interface{}
+	}{
+		// The catalog words analyzed to the stem of each query word
+		{"title:stories summary:movie", []interface{}{"%stories%", "%story%", "%movie%", "%movies%"}},
+		{"title:harry", []interface{}{"%harry%"}},
+		// Typo corrections are the words they were corrected from
+		{"summary:moveis", []interface{}{"%moveis%", "%movies%", "%movie%"}},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, args, ok := likePlan(stats, columns, stats.planQuery(expr))
+		if !ok || !reflect.DeepEqual(args, test.want) {
+			t.Errorf("likePlan(%q) = %q, want %q", test.query, args, test.want)
+		}
+	}
+}
+
+func TestLikePlanEscapesWildcards(t *testing.T) {
+	stats := NewCorpusStats(nil)
+	word := queryTerm{text: `100%_\`, forms: []string{`100%_\`}}
+	plan := []clausePlan{{operands: []operandPlan{{
+		fields: []string{"title"},
+		words:  []queryTerm{word},
+		terms:  map[string][]queryTerm{"title": {word}},
+	}}}}
+
+	where, args, ok := likePlan(stats, map[string]string{"title": "title"}, plan)
+	if want := []interface{}{`%100\%\_\\%`}; !ok || where != "(((title LIKE ?)))" || !reflect.DeepEqual(args, want) {
+		t.Errorf("likePlan = %q, %q, want %q", where, args, want)
+	}
+}
@@ -0,0 +1,262 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FieldScopes maps the field names accepted in "field:term" to the
+// searchable fields they cover
+var FieldScopes = map[string][]string{
+	"title":     {"title"},
+	"author":    {"authors"},
+	"authors":   {"authors"},
+	"publisher": {"publisher"},
+	"director":  {"director"},
+	"writer":    {"writers"},
+	"writers":   {"writers"},
+	"cast":      {"cast"},
+	"actor":     {"cast"},
+	"summary":   {"summary", "short_summary"},
+}
+
+// Expr is a parsed query. A document matches when it matches every clause
+// that is not excluded and none of the excluded ones.
+//
+// The query language is a list of clauses separated by spaces:
+//
+//	harry potter           both words, in any field
+//	"half-blood prince"    the words next to each other, in this order
+//	-twilight              documents without the word
+//	author:rowling         the word in the authors field
+//	rowling OR tolkien     either word; OR binds tighter than the spaces
+//
+// The last word of a query also matches as a prefix, and every word that is
+// not excluded can match with a few typos.
+type Expr struct {
+	Clauses []Clause
+}
+
+// Clause is one or more alternatives joined by OR, or a single excluded operand
+type Clause struct {
+	Operands []Operand
+	Exclude  bool
+}
+
+// Operand is a word or a phrase, optionally scoped to a field
+type Operand struct {
+	// Field is the scope written before the colon, "" for every field
+	Field string
+	// Words are the tokenized words; several words must appear as a phrase
+	Words []string
+	// Prefix is set on the last word of the query, which also matches as a prefix
+	Prefix bool
+	// Pos is the rune offset of the operand in the query
+	Pos int
+
+	quoted bool
+}
+
+// Fields returns the searchable fields the operand is matched against
+func (o Operand) Fields() []string {
+	if o.Field == "" {
+		return SearchableFields
+	}
+	return FieldScopes[o.Field]
+}
+
+// ParseError describes malformed query syntax
+type ParseError struct {
+	// Pos is the rune offset of the error in the query
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos, e.Msg)
+}
+
+// token is a lexical token of a query
+type token struct {
+	pos     int
+	or      bool // the OR operator
+	exclude bool
+	field   string
+	text    string
+	phrase  bool // text was quoted
+}
+
+// ParseQuery parses a search query into an Expr. An empty query yields an
+// Expr without clauses.
+func ParseQuery(query string) (*Expr, error) {
+	tokens, err := lexQuery([]rune(query))
+	if err != nil {
+		return nil, err
+	}
+
+	expr := &Expr{}
+	afterOr, orPos := false, 0
+	for i, tok := range tokens {
+		if tok.or {
+			if len(expr.Clauses) == 0 || afterOr || i == len(tokens)-1 {
+				return nil, &ParseError{Pos: tok.pos, Msg: "OR must be placed between two terms"}
+			}
+			afterOr, orPos = true, tok.pos
+			continue
+		}
+
+		words := analysis.Tokenize(tok.text)
+		if len(words) == 0 {
+			if tok.exclude || tok.field != "" || tok.phrase {
+				return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("%q has no words to search for", tok.text)}
+			}
+			// Skip bare punctuation, unless it is needed by an OR
+			if afterOr {
+				return nil, &ParseError{Pos: orPos, Msg: "OR must be placed between two terms"}
+			}
+			continue
+		}
+
+		operand := Operand{Field: tok.field, Words: words, Pos: tok.pos, quoted: tok.phrase}
+		if afterOr {
+			last := &expr.Clauses[len(expr.Clauses)-1]
+			if last.Exclude || tok.exclude {
+				return nil, &ParseError{Pos: orPos, Msg: "an excluded term cannot be combined with OR"}
+			}
+			last.Operands = append(last.Operands, operand)
+			afterOr = false
+			continue
+		}
+		expr.Clauses = append(expr.Clauses, Clause{Operands: []Operand{operand}, Exclude: tok.exclude})
+	}
+
+	if len(expr.Clauses) == 0 {
+		return expr, nil
+	}
+	if len(expr.positive()) == 0 {
+		return nil, &ParseError{Pos: 0, Msg: "the query only excludes terms, add a term to search for"}
+	}
+
+	// The last word is still being typed, so it also matches as a prefix
+	last := &expr.Clauses[len(expr.Clauses)-1]
+	if op := &last.Operands[len(last.Operands)-1]; !last.Exclude && len(op.Words) == 1 && !op.quoted {
+		op.Prefix = true
+	}
+	return expr, nil
+}
+
+// lexQuery splits a query into tokens
+func lexQuery(query []rune) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(query) {
+		if unicode.IsSpace(query[i]) {
+			i++
+			continue
+		}
+
+		tok := token{pos: i}
+		if query[i] == '-' {
+			// A dash followed by a space is punctuation, not an exclusion
+			if i+1 == len(query) || unicode.IsSpace(query[i+1]) {
+				i++
+				continue
+			}
+			tok.exclude = true
+			i++
+		}
+
+		// A field scope is a known name directly followed by a colon and a
+		// term. Any other name is text, as in "re:zero" or "Mission:Impossible".
+		if end := fieldEnd(query, i); end > i {
+			name := strings.ToLower(string(query[i:end]))
+			if _, ok := FieldScopes[name]; ok {
+				tok.field = name
+				i = end + 1
+			}
+		}
+
+		if query[i] == '"' {
+			end := i + 1
+			for end < len(query) && query[end] != '"' {
+				end++
+			}
+			if end == len(query) {
+				return nil, &ParseError{Pos: i, Msg: "unterminated phrase, missing closing quote"}
+			}
+			tok.text, tok.phrase = string(query[i+1:end]), true
+			i = end + 1
+		} else {
+			start := i
+			for i < len(query) && !unicode.IsSpace(query[i]) && query[i] != '"' {
+				i++
+			}
+			tok.text = string(query[start:i])
+			tok.or = tok.text == "OR" && !tok.exclude && tok.field == ""
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// fieldEnd returns the offset of the colon ending a field scope starting at
+// i, or i if there is none
+func fieldEnd(query []rune, i int) int {
+	end := i
+	for end < len(query) && unicode.IsLetter(query[end]) {
+		end++
+	}
+	if end == i || end+1 >= len(query) || query[end] != ':' || unicode.IsSpace(query[end+1]) {
+		return i
+	}
+	return end
+}
+
+// positive returns the clauses that are not excluded
+func (e *Expr) positive() []Clause {
+	var clauses []Clause
+	for _, clause := range e.Clauses {
+		if !clause.Exclude {
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses
+}
+
+// Text returns the words the query searches for, without operators,
+// exclusions or field scopes
+func (e *Expr) Text() string {
+	var words []string
+	for _, clause := range e.positive() {
+		for _, op := range clause.Operands {
+			words = append(words, op.Words...)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// String formats the expression in the query language, in a canonical form
+func (e *Expr) String() string {
+	clauses := make([]string, len(e.Clauses))
+	for i, clause := range e.Clauses {
+		operands := make([]string, len(clause.Operands))
+		for j, op := range clause.Operands {
+			text := strings.Join(op.Words, " ")
+			lastWord := i == len(e.Clauses)-1 && j == len(clause.Operands)-1 && !clause.Exclude
+			if len(op.Words) > 1 || (lastWord && !op.Prefix) {
+				text = `"` + text + `"`
+			}
+			if op.Field != "" {
+				text = op.Field + ":" + text
+			}
+			operands[j] = text
+		}
+		clauses[i] = strings.Join(operands, " OR ")
+		if clause.Exclude {
+			clauses[i] = "-" + clauses[i]
+		}
+	}
+	return strings.Join(clauses, " ")
+}
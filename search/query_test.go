@@ -0,0 +1,109 @@
+package search
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Harry Potter", "harry potter"},
+		{`"half-blood prince" -twilight`, `"half blood prince" -twilight`},
+		{"half-blood prince", `"half blood" prince`},
+		{"author:Rowling OR author:tolkien hobbit", "author:rowling OR author:tolkien hobbit"},
+		{"director:nolan -cast:bale", "director:nolan -cast:bale"},
+		{`harry "potter"`, `harry "potter"`},
+		{"Patton Oswalt: Annihilation", "patton oswalt annihilation"},
+		{"spider - man", "spider man"},
+		{"Mission:Impossible", `"mission impossible"`},
+		{"Road:T", `"road t"`},
+		{"Title:dune", "title:dune"},
+		{"harry -Foo:bar", `harry -"foo bar"`},
+		{"re:zero", `"re zero"`},
+		{"star trek:nemesis", `star "trek nemesis"`},
+		{"mission:impossible", `"mission impossible"`},
+		{"harry -autor:rowling", `harry -"autor rowling"`},
+		{"", ""},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) returned error %v", test.query, err)
+			continue
+		}
+		if got := expr.String(); got != test.want {
+			t.Errorf("ParseQuery(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`harry "potter`, 6},
+		{"OR harry", 0},
+		{"harry OR", 6},
+		{"harry OR OR potter", 9},
+		{"-twilight", 0},
+		{"harry -potter OR stone", 14},
+		{"-autor:rowling", 0},
+		{`title:"" harry`, 0},
+	}
+	for _, test := range tests {
+		_, err := ParseQuery(test.query)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("ParseQuery(%q) returned %v, want a ParseError", test.query, err)
+			continue
+		}
+		if perr.Pos != test.pos {
+			t.Errorf("ParseQuery(%q) reported position %d (%v), want %d", test.query, perr.Pos, perr, test.pos)
+		}
+	}
+}
+
+func TestIndexSearchQuerySyntax(t *testing.T) {
+	ix := NewIndex([]*Document{
+		{ID: 1, Type: "book", Title: "Harry Potter and the Half-Blood Prince", Authors: "J.K. Rowling"},
+		{ID: 2, Type: "book", Title: "The Prince", Authors: "Niccolò Machiavelli"},
+		{ID: 3, Type: "book", Title: "Twilight", Authors: "Stephenie Meyer"},
+		{ID: 4, Type: "movie", Title: "The Prestige", Director: "Christopher Nolan"},
+		{ID: 5, Type: "book", Title: "The Hobbit", Authors: "J.R.R. Tolkien"},
+	})
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{`"half-blood prince"`, []int{1}},
+		{`"prince half-blood"`, nil},
+		{"prince", []int{1, 2}},
+		{"the -prince", []int{4, 5}},
+		{"author:rowling OR author:tolkien", []int{1, 5}},
+		{"director:nolan", []int{4}},
+		{"title:nolan", nil},
+		{"author:rowlnig", []int{1}},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		var got []int
+		for _, hit := range ix.Search(expr) {
+			got = append(got, hit.Doc.ID)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("Search(%q) = %v, want %v", test.query, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("Search(%q) = %v, want %v", test.query, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package search
+
+import (
+	"anghami-exercise/editdistance"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Scorer computes one ranking signal for every hit of a query. Scores are
+// normalized to [0, 1] so that stages can be combined with weights.
+type Scorer interface {
+	Name() string
+	Score(query string, hits []Hit) []float64
+}
+
+// Stage is a named scorer and the weight of its score in the final ranking
+type Stage struct {
+	Scorer Scorer
+	Weight float64
+}
+
+// Pipeline ranks hits by the weighted sum of its stages' scores. Hits that
+// needed fewer typos to match always come first, so exact matches outrank
+// fuzzy ones. When two hits have the same total, the stages are compared in
+// order to break the tie.
+type Pipeline struct {
+	Stages []Stage
+}
+
+// RankedHit is a hit with its final score and the score of every stage
+type RankedHit struct {
+	Hit
+	Total  float64
+	Scores map[string]float64
+}
+
+// DefaultPipeline ranks primarily by text match, then favors titles close to
+// the query, popular items and recent releases
+func DefaultPipeline() *Pipeline {
+	return &Pipeline{Stages: []Stage{
+		{Scorer: TextMatchScorer{}, Weight: 1.0},
+		{Scorer: EditDistanceScorer{}, Weight: 0.3},
+		{Scorer: PopularityScorer{}, Weight: 0.2},
+		{Scorer: FreshnessScorer{}, Weight: 0.05},
+	}}
+}
+
+// With returns a copy of the pipeline with the stages added
+func (p *Pipeline) With(stages ...Stage) *Pipeline {
+	if len(stages) == 0 {
+		return p
+	}
+	return &Pipeline{Stages: append(append([]Stage(nil), p.Stages...), stages...)}
+}
+
+// Rank scores the hits with every stage and sorts them best first
+func (p *Pipeline) Rank(query string, hits []Hit) []RankedHit {
+	ranked := make([]RankedHit, len(hits))
+	stageScores := make([][]float64, len(p.Stages))
+	for s, stage := range p.Stages {
+		stageScores[s] = stage.Scorer.Score(query, hits)
+	}
+
+	for i, hit := range hits {
+		ranked[i] = RankedHit{Hit: hit, Scores: make(map[string]float64, len(p.Stages))}
+		for s, stage := range p.Stages {
+			score := stageScores[s][i]
+			ranked[i].Scores[stage.Scorer.Name()] = score
+			ranked[i].Total += stage.Weight * score
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Typos != ranked[j].Typos {
+			return ranked[i].Typos < ranked[j].Typos
+		}
+		if ranked[i].Total != ranked[j].Total {
+			return ranked[i].Total > ranked[j].Total
+		}
+		for _, stage := range p.Stages {
+			name := stage.Scorer.Name()
+			if ranked[i].Scores[name] != ranked[j].Scores[name] {
+				return ranked[i].Scores[name] > ranked[j].Scores[name]
+			}
+		}
+		if len(ranked[i].Doc.Title) != len(ranked[j].Doc.Title) {
+			return len(ranked[i].Doc.Title) < len(ranked[j].Doc.Title)
+		}
+		return ranked[i].Doc.Key() < ranked[j].Doc.Key()
+	})
+
+	return ranked
+}
+
+// TextMatchScorer is the backend's BM25 score relative to the best hit
+type TextMatchScorer struct{}
+
+func (TextMatchScorer) Name() string { return "text_match" }
+
+func (TextMatchScorer) Score(query string, hits []Hit) []float64 {
+	best := 0.0
+	for _, hit := range hits {
+		best = math.Max(best, hit.Score)
+	}
+
+	scores := make([]float64, len(hits))
+	if best == 0 {
+		return scores
+	}
+	for i, hit := range hits {
+		scores[i] = hit.Score / best
+	}
+	return scores
+}
+
+// EditDistanceScorer favors titles that are close to the whole query
+type EditDistanceScorer struct{}
+
+func (EditDistanceScorer) Name() string { return "edit_distance" }
+
+func (EditDistanceScorer) Score(query string, hits []Hit) []float64 {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	scores := make([]float64, len(hits))
+	for i, hit := range hits {
+		title := strings.ToLower(hit.Doc.Title)
+		longest := max(utf8.RuneCountInString(query), utf8.RuneCountInString(title))
+		if longest == 0 {
+			continue
+		}
+		scores[i] = 1 - float64(editdistance.Distance(query, title))/float64(longest)
+	}
+	return scores
+}
+
+// PopularityScorer favors highly rated items. Books are rated out of 5 and
+// their rating is trusted more as ratings_count grows; movies are rated out of 10.
+type PopularityScorer struct{}
+
+func (PopularityScorer) Name() string { return "popularity" }
+
+// popularRatingsCount is the number of ratings at which a book's rating is fully trusted
+const popularRatingsCount = 10000
+
+func (PopularityScorer) Score(query string, hits []Hit) []float64 {
+	scores := make([]float64, len(hits))
+	for i, hit := range hits {
+		scores[i] = popularity(hit.Doc)
+	}
+	return scores
+}
+
+// popularity rates a document between 0 and 1 from its rating and, for
+// books, its ratings_count
+func popularity(doc *Document) float64 {
+	if doc.Type == "movie" {
+		return doc.Rating / 10
+	}
+	confidence := math.Min(1, math.Log1p(float64(doc.RatingsCount))/math.Log1p(popularRatingsCount))
+	return doc.Rating / 5 * confidence
+}
+
+// FreshnessScorer favors recent items relative to the oldest and newest hit
+type FreshnessScorer struct{}
+
+func (FreshnessScorer) Name() string { return "freshness" }
+
+func (FreshnessScorer) Score(query string, hits []Hit) []float64 {
+	oldest, newest := 0, 0
+	for _, hit := range hits {
+		if hit.Doc.Year == 0 {
+			continue
+		}
+		if oldest == 0 || hit.Doc.Year < oldest {
+			oldest = hit.Doc.Year
+		}
+		newest = max(newest, hit.Doc.Year)
+	}
+
+	scores := make([]float64, len(hits))
+	if newest == oldest {
+		return scores
+	}
+	for i, hit := range hits {
+		if hit.Doc.Year != 0 {
+			scores[i] = float64(hit.Doc.Year-oldest) / float64(newest-oldest)
+		}
+	}
+	return scores
+}
+
+// YearScorer favors the items released or published in a year
+type YearScorer struct {
+	Year int
+}
+
+func (YearScorer) Name() string { return "year" }
+
+func (s YearScorer) Score(query string, hits []Hit) []float64 {
+	scores := make([]float64, len(hits))
+	for i, hit := range hits {
+		if hit.Doc.Year == s.Year {
+			scores[i] = 1
+		}
+	}
+	return scores
+}
+
+// RatingScorer favors highly rated items on their own scale, out of 5 for
+// books and 10 for movies, however many ratings they have
+type RatingScorer struct{}
+
+func (RatingScorer) Name() string { return "rating" }
+
+func (RatingScorer) Score(query string, hits []Hit) []float64 {
+	scores := make([]float64, len(hits))
+	for i, hit := range hits {
+		scores[i] = NormalizedRating(hit.Doc)
+	}
+	return scores
+}
+
+// NormalizedRating returns the rating of a document between 0 and 1, from
+// its scale of 5 for books or 10 for movies
+func NormalizedRating(doc *Document) float64 {
+	if doc.Type == "movie" {
+		return doc.Rating / 10
+	}
+	return doc.Rating / 5
+}
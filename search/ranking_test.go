@@ -0,0 +1,95 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fixedScorer gives the hits the scores it holds, in order
+type fixedScorer struct {
+	name   string
+	scores []float64
+}
+
+func (s fixedScorer) Name() string { return s.name }
+
+func (s fixedScorer) Score(query string, hits []Hit) []float64 { return s.scores }
+
+func TestPipelineRank(t *testing.T) {
+	hits := func(typos ...int) []Hit {
+		titles := []string{"Dune", "Dune Messiah", "Dune"}
+		hits := make([]Hit, len(typos))
+		for i := range hits {
+			hits[i] = Hit{Doc: &Document{ID: 3 - i, Type: "book", Title: titles[i]}, Typos: typos[i]}
+		}
+		return hits
+	}
+
+	tests := []struct {
+		name   string
+		stages []Stage
+		hits   []Hit
+		want   []int
+	}{
+		{
+			name: "weights",
+			stages: []Stage{
+				{Scorer: fixedScorer{"a", []float64{0.2, 0.9, 0.5}}, Weight: 1},
+				{Scorer: fixedScorer{"b", []float64{1, 0, 0}}, Weight: 0.1},
+			},
+			hits: hits(0, 0, 0),
+			want: []int{2, 1, 3},
+		},
+		{
+			name: "heavier second stage",
+			stages: []Stage{
+				{Scorer: fixedScorer{"a", []float64{0.2, 0.9, 0.5}}, Weight: 1},
+				{Scorer: fixedScorer{"b", []float64{1, 0, 0}}, Weight: 1},
+			},
+			hits: hits(0, 0, 0),
+			want: []int{3, 2, 1},
+		},
+		{
+			name:   "fewer typos first",
+			stages: []Stage{{Scorer: fixedScorer{"a", []float64{1, 0.5, 0}}, Weight: 1}},
+			hits:   hits(2, 1, 0),
+			want:   []int{1, 2, 3},
+		},
+		{
+			name: "equal totals by stage order",
+			stages: []Stage{
+				{Scorer: fixedScorer{"a", []float64{0.25, 0.75, 0.5}}, Weight: 1},
+				{Scorer: fixedScorer{"b", []float64{0.75, 0.25, 0.5}}, Weight: 1},
+			},
+			hits: hits(0, 0, 0),
+			want: []int{2, 1, 3},
+		},
+		{
+			name:   "equal scores by title length, then key",
+			stages: []Stage{{Scorer: fixedScorer{"a", []float64{0.5, 0.5, 0.5}}, Weight: 1}},
+			hits:   hits(0, 0, 0),
+			want:   []int{1, 3, 2},
+		},
+	}
+	for _, test := range tests {
+		p := &Pipeline{Stages: test.stages}
+		var got []int
+		for _, hit := range p.Rank("dune", test.hits) {
+			got = append(got, hit.Doc.ID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Rank = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPipelineRankScores(t *testing.T) {
+	p := (&Pipeline{Stages: []Stage{{Scorer: fixedScorer{"a", []float64{0.5}}, Weight: 2}}}).
+		With(Stage{Scorer: fixedScorer{"b", []float64{0.25}}, Weight: 0.5})
+	ranked := p.Rank("dune", []Hit{{Doc: &Document{ID: 1, Type: "book", Title: "Dune"}}})
+
+	want := map[string]float64{"a": 0.5, "b": 0.25}
+	if ranked[0].Total != 1.125 || !reflect.DeepEqual(ranked[0].Scores, want) {
+		t.Errorf("Rank = total %v, scores %v, want 1.125 and %v", ranked[0].Total, ranked[0].Scores, want)
+	}
+}
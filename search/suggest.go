@@ -0,0 +1,148 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxPastQueries caps the successful past queries loaded for suggestions
+const maxPastQueries = 10000
+
+// Speller suggests corrections for misspelled queries. A successful past
+// query a few typos away is preferred, as people already found what they
+// wanted with it; otherwise every word missing from the vocabulary is
+// replaced by its closest, most frequent vocabulary word.
+type Speller struct {
+	words   *FuzzyDictionary
+	queries map[string]pastQuery // by normalized text
+	past    *FuzzyDictionary     // the normalized past queries, by clicks
+}
+
+// pastQuery is a query that led to clicks
+type pastQuery struct {
+	text   string
+	clicks int
+}
+
+// NewSpeller builds a speller over the words of the documents and the past
+// queries, which map to the number of clicks they led to
+func NewSpeller(docs []*Document, queries map[string]int) *Speller {
+	counts := make(map[string]int)
+	for _, doc := range docs {
+		seen := make(map[string]bool)
+		for _, field := range SearchableFields {
+			for _, word := range normalizer.Terms(doc.FieldValue(field)) {
+				if !seen[word] {
+					seen[word] = true
+					counts[word]++
+				}
+			}
+		}
+	}
+
+	s := &Speller{queries: make(map[string]pastQuery)}
+	for text, clicks := range queries {
+		key := normalizeWords(text)
+		if key == "" || s.queries[key].clicks >= clicks {
+			continue
+		}
+		s.queries[key] = pastQuery{text: text, clicks: clicks}
+		for _, word := range strings.Fields(key) {
+			counts[word] += clicks
+		}
+	}
+	s.words = NewFuzzyDictionary(counts)
+
+	clicks := make(map[string]int, len(s.queries))
+	for key, past := range s.queries {
+		clicks[key] = past.clicks
+	}
+	s.past = NewFuzzyDictionary(clicks)
+	return s
+}
+
+// Suggest returns a corrected query, or "" if there is nothing to correct.
+// Word corrections keep the query syntax, such as quotes and field scopes.
+func (s *Speller) Suggest(query string) string {
+	key := normalizeWords(query)
+	if s == nil || key == "" {
+		return ""
+	}
+	if _, ok := s.queries[key]; ok {
+		return ""
+	}
+
+	// The closest past query, then the one with the most clicks
+	if matches := s.past.Lookup(key); len(matches) > 0 {
+		return s.queries[matches[0].Term].text
+	}
+
+	runes := []rune(query)
+	var b strings.Builder
+	pos, corrected := 0, false
+	for _, w := range analysis.Words(query) {
+		// Field names and OR are syntax, not words to correct
+		if (w.End < len(runes) && runes[w.End] == ':') || string(runes[w.Start:w.End]) == "OR" {
+			continue
+		}
+		word := normalizer.Term(w.Text)
+		if s.words.Contains(word) {
+			continue
+		}
+		matches := s.words.Lookup(word)
+		if len(matches) == 0 {
+			continue
+		}
+		b.WriteString(string(runes[pos:w.Start]))
+		b.WriteString(matches[0].Term)
+		pos, corrected = w.End, true
+	}
+	if !corrected {
+		return ""
+	}
+	b.WriteString(string(runes[pos:]))
+	return b.String()
+}
+
+// LoadSuccessfulQueries reads the past queries that led to at least one
+// click, with their number of clicks. The search_events and search_clicks
+// tables are only created by the first report, so until then there are none.
+func LoadSuccessfulQueries(db *sql.DB) (map[string]int, error) {
+	var tables int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name IN ('search_events', 'search_clicks')
+	`).Scan(&tables)
+	if err != nil {
+		return nil, fmt.Errorf("error checking search event tables: %v", err)
+	}
+	if tables < 2 {
+		return nil, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT e.search_query, COUNT(*) AS clicks
+		FROM search_events e
+		JOIN search_clicks c ON c.search_id = e.search_id
+		GROUP BY e.search_query
+		ORDER BY clicks DESC
+		LIMIT ?
+	`, maxPastQueries)
+	if err != nil {
+		return nil, fmt.Errorf("error loading successful queries: %v", err)
+	}
+	defer rows.Close()
+
+	queries := make(map[string]int)
+	for rows.Next() {
+		var query string
+		var clicks int
+		if err := rows.Scan(&query, &clicks); err != nil {
+			return nil, err
+		}
+		queries[query] = clicks
+	}
+	return queries, rows.Err()
+}
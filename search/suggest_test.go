@@ -0,0 +1,31 @@
+package search
+
+import "testing"
+
+func TestSpellerSuggest(t *testing.T) {
+	speller := NewSpeller([]*Document{
+		{ID: 1, Type: "book", Title: "Harry Potter and the Half-Blood Prince", Authors: "J.K. Rowling"},
+		{ID: 2, Type: "book", Title: "The Hobbit", Authors: "J.R.R. Tolkien"},
+		{ID: 3, Type: "movie", Title: "The Prestige", Director: "Christopher Nolan"},
+	}, map[string]int{"Harry Potter": 5, "hobbit": 1})
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"hary potter", "Harry Potter"},
+		{"harry pottre", "Harry Potter"},
+		{"the hobit", "the hobbit"},
+		{`"the prestiege" -hobit`, `"the prestige" -hobbit`},
+		{"tolkein", "tolkien"}, // a swapped pair of letters
+		{"author:rowlnig OR tolkein", "author:rowling OR tolkien"},
+		{"harry potter", ""},
+		{"the hobbit", ""},
+		{"xyzzy", ""},
+	}
+	for _, test := range tests {
+		if got := speller.Suggest(test.query); got != test.want {
+			t.Errorf("Suggest(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
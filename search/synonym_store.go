@@ -0,0 +1,349 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SynonymSource is where synonym rules are stored
+type SynonymSource interface {
+	// Load returns every rule
+	Load() ([]SynonymRule, error)
+	// Add stores a new rule and returns it with its ID
+	Add(rule SynonymRule) (SynonymRule, error)
+	// Delete removes the rule with the given ID
+	Delete(id int) error
+}
+
+// ErrSynonymNotFound is returned when deleting a rule that does not exist
+var ErrSynonymNotFound = errors.New("synonym rule not found")
+
+// defaultSynonymsFile is the synonyms file used when no source is configured
+const defaultSynonymsFile = "synonyms.txt"
+
+// NewSynonymSource returns the DB table source for "db" and a file source
+// for any other name, defaulting to synonyms.txt
+func NewSynonymSource(name string, db *sql.DB) (SynonymSource, error) {
+	if name == "db" {
+		source := &DBSynonymSource{db: db}
+		if err := source.createTable(); err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+	if name == "" {
+		name = defaultSynonymsFile
+	}
+	return &FileSynonymSource{Path: name}, nil
+}
+
+// FileSynonymSource stores rules in a text file in the ParseSynonymRules
+// format. A missing file has no rules. A rule's ID is a hash of its text, so
+// it does not change when other rules are added or deleted. Adding and
+// deleting rules keeps the file's comments and blank lines.
+type FileSynonymSource struct {
+	Path string
+}
+
+// Load parses the file
+func (f *FileSynonymSource) Load() ([]SynonymRule, error) {
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening synonyms file: %v", err)
+	}
+	defer file.Close()
+
+	rules, err := ParseSynonymRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", f.Path, err)
+	}
+	for i := range rules {
+		rules[i].ID = fileRuleID(rules[i])
+	}
+	return rules, nil
+}
+
+// Add appends the rule to the file, unless the file already has it
+func (f *FileSynonymSource) Add(rule SynonymRule) (SynonymRule, error) {
+	rules, err := f.Load()
+	if err != nil {
+		return rule, err
+	}
+	rule.ID = fileRuleID(rule)
+	for _, r := range rules {
+		if r.ID == rule.ID {
+			return r, nil
+		}
+	}
+
+	lines, err := f.lines()
+	if err != nil {
+		return rule, err
+	}
+	return rule, f.write(append(lines, rule.String()))
+}
+
+// Delete rewrites the file without the rule's lines
+func (f *FileSynonymSource) Delete(id int) error {
+	// A file that does not parse is left for its author to fix
+	if _, err := f.Load(); err != nil {
+		return err
+	}
+	lines, err := f.lines()
+	if err != nil {
+		return err
+	}
+
+	var kept []string
+	for _, line := range lines {
+		if isSynonymRule(line) {
+			rules, err := ParseSynonymRules(strings.NewReader(line))
+			if err == nil && len(rules) == 1 && fileRuleID(rules[0]) == id {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == len(lines) {
+		return ErrSynonymNotFound
+	}
+	return f.write(kept)
+}
+
+// fileRuleID hashes the canonical text of the rule into a positive ID
+func fileRuleID(rule SynonymRule) int {
+	h := fnv.New32a()
+	h.Write([]byte(rule.String()))
+	return int(h.Sum32() & math.MaxInt32)
+}
+
+// lines reads the lines of the file, with none for a missing file
+func (f *FileSynonymSource) lines() ([]string, error) {
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading synonyms file: %v", err)
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// write replaces the file with the lines. They are written to a temporary
+// file first so a reader never sees half a file.
+func (f *FileSynonymSource) write(lines []string) error {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("error writing synonyms file: %v", err)
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		return fmt.Errorf("error writing synonyms file: %v", err)
+	}
+	return nil
+}
+
+// DBSynonymSource stores rules in the synonyms table, one row per rule
+// with its phrases in the file format
+type DBSynonymSource struct {
+	db *sql.DB
+}
+
+func (s *DBSynonymSource) createTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS synonyms (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			rule VARCHAR(1024) NOT NULL
+		)
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return fmt.Errorf("error creating synonyms table: %v", err)
+	}
+	return nil
+}
+
+// Load reads every row of the synonyms table
+func (s *DBSynonymSource) Load() ([]SynonymRule, error) {
+	rows, err := s.db.Query("SELECT id, rule FROM synonyms ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error loading synonyms: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []SynonymRule
+	for rows.Next() {
+		var id int
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			return nil, err
+		}
+		parsed, err := ParseSynonymRules(strings.NewReader(text))
+		if err != nil || len(parsed) != 1 {
+			log.Printf("Skipping invalid synonym rule %d: %q", id, text)
+			continue
+		}
+		parsed[0].ID = id
+		rules = append(rules, parsed[0])
+	}
+	return rules, rows.Err()
+}
+
+// Add inserts the rule
+func (s *DBSynonymSource) Add(rule SynonymRule) (SynonymRule, error) {
+	result, err := s.db.Exec("INSERT INTO synonyms (rule) VALUES (?)", rule.String())
+	if err != nil {
+		return rule, fmt.Errorf("error inserting synonym rule: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return rule, err
+	}
+	rule.ID = int(id)
+	return rule, nil
+}
+
+// Delete removes the rule's row
+func (s *DBSynonymSource) Delete(id int) error {
+	result, err := s.db.Exec("DELETE FROM synonyms WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error deleting synonym rule: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSynonymNotFound
+	}
+	return nil
+}
+
+// SynonymStore keeps the active synonyms in sync with their source. Every
+// change, whether made through the store or found by Reload, is applied to
+// searches immediately and reported to OnChange.
+type SynonymStore struct {
+	mu     sync.Mutex
+	source SynonymSource
+	rules  []SynonymRule
+
+	// OnChange, when set, is called with the phrases of the rules that were
+	// added or removed, so that cached results using them can be dropped
+	OnChange func(phrases []string)
+}
+
+// NewSynonymStore creates a store for the source. Call Reload to load it.
+func NewSynonymStore(source SynonymSource) *SynonymStore {
+	return &SynonymStore{source: source}
+}
+
+// Rules returns the active rules
+func (s *SynonymStore) Rules() []SynonymRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SynonymRule{}, s.rules...)
+}
+
+// Add validates and stores a rule, and applies it
+func (s *SynonymStore) Add(rule SynonymRule) (SynonymRule, error) {
+	if err := rule.Validate(); err != nil {
+		return rule, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, err := s.source.Add(rule)
+	if err != nil {
+		return rule, err
+	}
+	return rule, s.reload()
+}
+
+// Delete removes a rule from the source and stops applying it
+func (s *SynonymStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.source.Delete(id); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// Reload reads the rules from the source again and applies them if they changed
+func (s *SynonymStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload()
+}
+
+// reload reads and applies the rules; the caller must hold s.mu
+func (s *SynonymStore) reload() error {
+	rules, err := s.source.Load()
+	if err != nil {
+		return err
+	}
+
+	changed := changedPhrases(s.rules, rules)
+	s.rules = rules
+	if len(changed) == 0 {
+		return nil
+	}
+
+	SetSynonyms(NewSynonyms(rules))
+	if s.OnChange != nil {
+		s.OnChange(changed)
+	}
+	return nil
+}
+
+// Watch reloads the rules every interval, so that edits made directly to the
+// file or table apply without a restart
+func (s *SynonymStore) Watch(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := s.Reload(); err != nil {
+				log.Printf("Error reloading synonyms: %v", err)
+			}
+		}
+	}()
+}
+
+// changedPhrases returns the phrases of the rules that are only in one of
+// the two lists, ignoring IDs
+func changedPhrases(before, after []SynonymRule) []string {
+	var phrases []string
+	for _, pair := range [][2][]SynonymRule{{before, after}, {after, before}} {
+		for _, rule := range pair[0] {
+			if !containsRule(pair[1], rule) {
+				phrases = append(phrases, rule.Phrases...)
+			}
+		}
+	}
+	return phrases
+}
+
+func containsRule(rules []SynonymRule, rule SynonymRule) bool {
+	for _, r := range rules {
+		if r.OneWay == rule.OneWay && reflect.DeepEqual(r.Phrases, rule.Phrases) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package search
+
+import (
+	"anghami-exercise/analysis"
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// SynonymRule is a set of equivalent phrases. A two-way rule expands every
+// phrase to all the others; a one-way rule only expands its first phrase.
+type SynonymRule struct {
+	ID      int      `json:"id"`
+	Phrases []string `json:"phrases"`
+	OneWay  bool     `json:"one_way"`
+}
+
+// Validate reports whether the rule can be used. Phrases cannot contain the
+// separators of the file format, so that String parses back to the same rule.
+func (r SynonymRule) Validate() error {
+	if len(r.Phrases) < 2 {
+		return fmt.Errorf("a synonym rule needs at least two phrases")
+	}
+	for _, phrase := range r.Phrases {
+		if strings.ContainsAny(phrase, ",\r\n") || strings.Contains(phrase, "=>") {
+			return fmt.Errorf("synonym phrase %q cannot contain \",\", \"=>\" or a line break", phrase)
+		}
+		if strings.HasPrefix(strings.TrimSpace(phrase), "#") {
+			return fmt.Errorf("synonym phrase %q cannot start with \"#\"", phrase)
+		}
+		if len(analysis.Tokenize(phrase)) == 0 {
+			return fmt.Errorf("synonym phrase %q has no words", phrase)
+		}
+	}
+	return nil
+}
+
+// String formats the rule in the synonyms file format
+func (r SynonymRule) String() string {
+	if r.OneWay {
+		return r.Phrases[0] + " => " + strings.Join(r.Phrases[1:], ", ")
+	}
+	return strings.Join(r.Phrases, ", ")
+}
+
+// ParseSynonymRules reads rules in the synonyms file format, one per line:
+//
+//	hp, harry potter                 two-way
+//	lotr => lord of the rings        one-way
+//	# comment
+//
+// Rules are numbered from 1 in the order they appear.
+func ParseSynonymRules(r io.Reader) ([]SynonymRule, error) {
+	var rules []SynonymRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if !isSynonymRule(text) {
+			continue
+		}
+
+		rule := SynonymRule{ID: len(rules) + 1}
+		from, to, oneWay := strings.Cut(text, "=>")
+		if oneWay {
+			rule.OneWay = true
+			rule.Phrases = append([]string{strings.TrimSpace(from)}, splitPhrases(to)...)
+		} else {
+			rule.Phrases = splitPhrases(text)
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// isSynonymRule reports whether a line of the synonyms file is a rule rather
+// than a comment or a blank line
+func isSynonymRule(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && !strings.HasPrefix(line, "#")
+}
+
+func splitPhrases(s string) []string {
+	var phrases []string
+	for _, phrase := range strings.Split(s, ",") {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+	return phrases
+}
+
+// Synonyms expands query words into their synonyms
+type Synonyms struct {
+	// expansions maps the words of a phrase, joined by spaces, to the words
+	// of the phrases it expands to
+	expansions map[string][][]string
+	maxWords   int // the longest phrase that expands
+}
+
+// NewSynonyms builds the expansions of the rules
+func NewSynonyms(rules []SynonymRule) *Synonyms {
+	s := &Synonyms{expansions: make(map[string][][]string)}
+	for _, rule := range rules {
+		phrases := make([][]string, len(rule.Phrases))
+		for i, phrase := range rule.Phrases {
+			phrases[i] = analysis.Tokenize(phrase)
+		}
+
+		for i, from := range phrases {
+			if rule.OneWay && i > 0 {
+				break
+			}
+			key := strings.Join(from, " ")
+			for j, to := range phrases {
+				if j != i && !containsPhrase(s.expansions[key], to) {
+					s.expansions[key] = append(s.expansions[key], to)
+				}
+			}
+			s.maxWords = max(s.maxWords, len(from))
+		}
+	}
+	return s
+}
+
+func containsPhrase(phrases [][]string, phrase []string) bool {
+	key := strings.Join(phrase, " ")
+	for _, p := range phrases {
+		if strings.Join(p, " ") == key {
+			return true
+		}
+	}
+	return false
+}
+
+// activeSynonyms are the synonyms applied to every search
+var activeSynonyms atomic.Pointer[Synonyms]
+
+// SetSynonyms replaces the synonyms applied to every search
+func SetSynonyms(s *Synonyms) {
+	activeSynonyms.Store(s)
+}
+
+// Expand returns the query with synonyms added as OR alternatives. An
+// operand whose words are a synonym phrase gets the other phrases as
+// alternatives in its clause. A run of single-word clauses forming a phrase,
+// such as "harry potter" for "hp", gets the synonym added to each of its
+// clauses, which keeps the words' own meaning while also matching the
+// synonym. Excluded phrases also exclude their synonyms.
+func (s *Synonyms) Expand(expr *Expr) *Expr {
+	if s == nil || len(s.expansions) == 0 {
+		return expr
+	}
+
+	expanded := &Expr{Clauses: make([]Clause, len(expr.Clauses))}
+	for i, clause := range expr.Clauses {
+		expanded.Clauses[i] = Clause{Operands: append([]Operand(nil), clause.Operands...), Exclude: clause.Exclude}
+	}
+
+	// Whole operands
+	var exclusions []Clause
+	for i, clause := range expr.Clauses {
+		for _, op := range clause.Operands {
+			for _, words := range s.expansions[strings.Join(op.Words, " ")] {
+				synonym := Operand{Field: op.Field, Words: words, Pos: op.Pos}
+				if clause.Exclude {
+					exclusions = append(exclusions, Clause{Operands: []Operand{synonym}, Exclude: true})
+				} else {
+					expanded.Clauses[i].Operands = append(expanded.Clauses[i].Operands, synonym)
+				}
+			}
+		}
+	}
+
+	// Runs of single-word clauses, longest first
+	for start := 0; start < len(expr.Clauses); start++ {
+		for n := min(s.maxWords, len(expr.Clauses)-start); n > 1; n-- {
+			run := expr.Clauses[start : start+n]
+			words, ok := runWords(run)
+			if !ok {
+				continue
+			}
+			synonyms := s.expansions[strings.Join(words, " ")]
+			if len(synonyms) == 0 {
+				continue
+			}
+			for i := range run {
+				for _, words := range synonyms {
+					synonym := Operand{Field: run[0].Operands[0].Field, Words: words, Pos: run[0].Operands[0].Pos}
+					expanded.Clauses[start+i].Operands = append(expanded.Clauses[start+i].Operands, synonym)
+				}
+			}
+			start += n - 1
+			break
+		}
+	}
+
+	expanded.Clauses = append(expanded.Clauses, exclusions...)
+	return expanded
+}
+
+// runWords returns the words of consecutive clauses that each have a single
+// single-word operand in the same field, or false if they do not
+func runWords(run []Clause) ([]string, bool) {
+	words := make([]string, len(run))
+	for i, clause := range run {
+		if clause.Exclude || len(clause.Operands) != 1 || len(clause.Operands[0].Words) != 1 ||
+			clause.Operands[0].Field != run[0].Operands[0].Field {
+			return nil, false
+		}
+		words[i] = clause.Operands[0].Words[0]
+	}
+	return words, true
+}
+
+// MatchesPhrase reports whether the query text uses any of the phrases, as
+// whole consecutive words
+func MatchesPhrase(query string, phrases []string) bool {
+	words := " " + strings.Join(analysis.Tokenize(query), " ") + " "
+	for _, phrase := range phrases {
+		if strings.Contains(words, " "+strings.Join(analysis.Tokenize(phrase), " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSynonymsExpand(t *testing.T) {
+	rules, err := ParseSynonymRules(strings.NewReader(`
+# comment
+lotr => lord of the rings
+hp, harry potter
+`))
+	if err != nil {
+		t.Fatalf("ParseSynonymRules: %v", err)
+	}
+	synonyms := NewSynonyms(rules)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"lotr", `lotr OR "lord of the rings"`},
+		{"lord of the rings", "lord of the rings"}, // one-way
+		{"hp prince", `hp OR "harry potter" prince`},
+		{"harry potter", `harry OR hp potter OR "hp"`},
+		{"author:hp", `author:hp OR author:"harry potter"`},
+		{"stone -hp", `stone -hp -"harry potter"`},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		if got := synonyms.Expand(expr).String(); got != test.want {
+			t.Errorf("Expand(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseSynonymRulesError(t *testing.T) {
+	_, err := ParseSynonymRules(strings.NewReader("hp, harry potter\nlotr =>\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("ParseSynonymRules returned %v, want an error on line 2", err)
+	}
+}
+
+func TestSynonymRuleValidate(t *testing.T) {
+	invalid := [][]string{
+		{"hp"},
+		{"hp", "..."},
+		{"hp", "harry, potter"},
+		{"hp => harry", "harry potter"},
+		{"hp", "harry\npotter"},
+		{"# hp", "harry potter"},
+	}
+	for _, phrases := range invalid {
+		if err := (SynonymRule{Phrases: phrases}).Validate(); err == nil {
+			t.Errorf("Validate(%q) accepted the rule", phrases)
+		}
+	}
+
+	// Valid rules parse back from their String
+	valid := []SynonymRule{
+		{ID: 1, Phrases: []string{"hp", "harry potter"}},
+		{ID: 1, Phrases: []string{"lotr", "lord of the rings", "the lord of the rings"}, OneWay: true},
+		{ID: 1, Phrases: []string{"c#", "c sharp", "hp #1"}},
+		{ID: 1, Phrases: []string{"a = b", "a > b"}, OneWay: true},
+	}
+	for _, rule := range valid {
+		if err := rule.Validate(); err != nil {
+			t.Errorf("Validate(%q): %v", rule.Phrases, err)
+			continue
+		}
+		parsed, err := ParseSynonymRules(strings.NewReader(rule.String()))
+		if err != nil || len(parsed) != 1 || !reflect.DeepEqual(parsed[0], rule) {
+			t.Errorf("ParseSynonymRules(%q) = %+v, %v, want %+v", rule.String(), parsed, err, rule)
+		}
+	}
+}
+
+func TestFileSynonymSourceKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	err := os.WriteFile(path, []byte("# Series\nhp, harry potter\n\n# Abbreviations\nlotr => lord of the rings\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &FileSynonymSource{Path: path}
+
+	if _, err := source.Add(SynonymRule{Phrases: []string{"sf", "science fiction"}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := source.Delete(fileRuleID(SynonymRule{Phrases: []string{"hp", "harry potter"}})); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Series\n\n# Abbreviations\nlotr => lord of the rings\nsf, science fiction\n"
+	if string(data) != want {
+		t.Errorf("the file is %q, want %q", data, want)
+	}
+}
+
+func TestFileSynonymSourceStableIDs(t *testing.T) {
+	source := &FileSynonymSource{Path: filepath.Join(t.TempDir(), "synonyms.txt")}
+	var ids []int
+	for _, phrases := range [][]string{{"hp", "harry potter"}, {"lotr", "lord of the rings"}, {"sf", "science fiction"}} {
+		rule, err := source.Add(SynonymRule{Phrases: phrases})
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		ids = append(ids, rule.ID)
+	}
+	if again, err := source.Add(SynonymRule{Phrases: []string{"hp", "harry potter"}}); err != nil || again.ID != ids[0] {
+		t.Fatalf("Add of an existing rule returned %+v, %v, want ID %d", again, err, ids[0])
+	}
+
+	// Deleting the first rule twice only deletes it, and the other rules
+	// keep their IDs
+	if err := source.Delete(ids[0]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := source.Delete(ids[0]); err != ErrSynonymNotFound {
+		t.Fatalf("Delete of a deleted rule returned %v, want ErrSynonymNotFound", err)
+	}
+	rules, err := source.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].ID != ids[1] || rules[1].ID != ids[2] {
+		t.Errorf("Load after deleting %d = %+v, want the rules %d and %d", ids[0], rules, ids[1], ids[2])
+	}
+}
+
+func TestSynonymStore(t *testing.T) {
+	defer SetSynonyms(nil)
+
+	var changed []string
+	store := NewSynonymStore(&FileSynonymSource{Path: filepath.Join(t.TempDir(), "synonyms.txt")})
+	store.OnChange = func(phrases []string) { changed = phrases }
+
+	rule, err := store.Add(SynonymRule{Phrases: []string{"lotr", "lord of the rings"}, OneWay: true})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if rules := store.Rules(); len(rules) != 1 || rules[0].ID != rule.ID {
+		t.Fatalf("Add returned %+v and the store has %+v", rule, store.Rules())
+	}
+	if strings.Join(changed, ",") != "lotr,lord of the rings" {
+		t.Fatalf("OnChange got %q after Add", changed)
+	}
+
+	expr, _ := Query{Text: "lotr"}.expression()
+	if got := expr.String(); got != `lotr OR "lord of the rings"` {
+		t.Fatalf("searches expand lotr to %q", got)
+	}
+
+	if err := store.Delete(rule.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(rule.ID); err != ErrSynonymNotFound {
+		t.Fatalf("Delete of a missing rule returned %v", err)
+	}
+	expr, _ = Query{Text: "lotr"}.expression()
+	if got := expr.String(); got != "lotr" {
+		t.Fatalf("searches still expand lotr to %q after Delete", got)
+	}
+}
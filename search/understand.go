@@ -0,0 +1,299 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interpretation is what query understanding recognized in a query: the
+// intent words turned into filters and ranking boosts, and the words left to
+// search for
+type Interpretation struct {
+	// Query is the words left to search for
+	Query string `json:"query"`
+	// Type is "book" or "movie", from words such as "movies" or "novels"
+	Type string `json:"type,omitempty"`
+	// Year restricts the results to a year, as in "movies 2017" or "from 2017"
+	Year int `json:"year,omitempty"`
+	// PreferYear ranks the results from a year first, as in "batman 1989"
+	PreferYear int `json:"prefer_year,omitempty"`
+	// By is the author of the books or the director of the movies
+	By string `json:"by,omitempty"`
+	// MinRating is the lowest rating, from "rated above N"
+	MinRating float64 `json:"min_rating,omitempty"`
+	// TopRated ranks highly rated results first, from "top rated"
+	TopRated bool `json:"top_rated,omitempty"`
+	// Description tells the user how the query was understood, such as
+	// "Showing movies from 2017"
+	Description string `json:"description"`
+
+	// Expr is the query without its intent words, where "by" is rewritten
+	// to field scopes
+	Expr *Expr `json:"-"`
+}
+
+// typeWords are the words that ask for a type. Only plurals are intents, so
+// "the jungle book" is still searched as a title.
+var typeWords = map[string]string{
+	"books": "book", "novels": "book",
+	"movies": "movie", "films": "movie",
+}
+
+// byScopes are the fields "by <name>" is searched in, by type
+var byScopes = map[string][]string{
+	"book":  {"author"},
+	"movie": {"director"},
+	"":      {"author", "director"},
+}
+
+// The weights of the ranking stages added for the boosts
+const (
+	preferYearWeight = 0.5
+	topRatedWeight   = 0.5
+)
+
+// Understand recognizes intent words in a parsed query and returns their
+// interpretation, or nil if the query has none. Intents are plain words,
+// never quoted, excluded, field-scoped or joined by OR:
+//
+//	movies, films, books, novels   the type
+//	2017, from 2017, in 2017       the year; a year after other words only
+//	                               boosts results from that year
+//	movies by nolan                the director, or the author of books;
+//	                               "by" must follow a type
+//	rated above 7, rated over 7    the lowest rating
+//	top rated, highest rated       highly rated results first
+func Understand(expr *Expr) *Interpretation {
+	in := &Interpretation{}
+	clauses := expr.Clauses
+	var rest []Clause
+	year, yearScoped := 0, false
+	for i := 0; i < len(clauses); i++ {
+		word := plainWord(clauses, i)
+		switch {
+		case typeWords[word] != "" && in.Type == "":
+			in.Type = typeWords[word]
+
+		case isTopRated(clauses, i):
+			in.TopRated = true
+			i++
+
+		case word == "rated" && (plainWord(clauses, i+1) == "above" || plainWord(clauses, i+1) == "over") && isRating(clauses, i+2):
+			in.MinRating, _ = number(clauses, i+2)
+			i += 2
+
+		case (word == "from" || word == "in") && isYear(clauses, i+1) && year == 0:
+			year, yearScoped = yearOf(clauses, i+1), true
+			i++
+
+		// A year alone, or first, is more likely a title, like "1984"
+		case isYear(clauses, i) && i == len(clauses)-1 && i > 0 && year == 0:
+			year = yearOf(clauses, i)
+
+		case word == "by" && in.Type != "" && in.By == "" && len(rest) == 0 && i+1 < len(clauses):
+			end := i + 1
+			for end < len(clauses) && plainWord(clauses, end) != "" && !startsIntent(clauses, end) {
+				end++
+			}
+			if end == i+1 {
+				rest = append(rest, clauses[i])
+				continue
+			}
+			rest = append(rest, byClause(clauses[i+1:end], in.Type))
+			in.By = strings.Join(rest[len(rest)-1].Operands[0].Words, " ")
+			i = end - 1
+
+		default:
+			rest = append(rest, clauses[i])
+		}
+	}
+
+	// A year is a filter when it is scoped by "from" or a type, or is all
+	// that is left; otherwise the words are the title and the year a hint
+	if year != 0 {
+		if yearScoped || in.Type != "" || len(rest) == 0 {
+			in.Year = year
+		} else {
+			in.PreferYear = year
+		}
+	}
+
+	if len(rest) == len(clauses) {
+		return nil
+	}
+	in.Expr = &Expr{Clauses: rest}
+	in.Query = in.Expr.Text()
+	in.Description = in.describe()
+	return in
+}
+
+// plainWord returns the lowercased word of clause i when it is a single
+// unquoted word without a field scope, or "" otherwise
+func plainWord(clauses []Clause, i int) string {
+	if i >= len(clauses) {
+		return ""
+	}
+	clause := clauses[i]
+	if clause.Exclude || len(clause.Operands) != 1 {
+		return ""
+	}
+	op := clause.Operands[0]
+	if op.Field != "" || op.quoted || len(op.Words) != 1 {
+		return ""
+	}
+	return strings.ToLower(op.Words[0])
+}
+
+// number parses clause i as a number. The tokenizer splits "4.5" into the
+// words "4" and "5", which are joined back.
+func number(clauses []Clause, i int) (float64, bool) {
+	if i >= len(clauses) {
+		return 0, false
+	}
+	clause := clauses[i]
+	if clause.Exclude || len(clause.Operands) != 1 {
+		return 0, false
+	}
+	op := clause.Operands[0]
+	if op.Field != "" || op.quoted || len(op.Words) > 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.Join(op.Words, "."), 64)
+	return n, err == nil
+}
+
+// isRating reports whether clause i is a rating between 0 and 10
+func isRating(clauses []Clause, i int) bool {
+	n, ok := number(clauses, i)
+	return ok && n >= 0 && n <= 10
+}
+
+// yearOf returns the year of clause i, or 0 if it is not a plausible year
+func yearOf(clauses []Clause, i int) int {
+	word := plainWord(clauses, i)
+	if len(word) != 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(word)
+	if err != nil || year < 1800 || year > time.Now().Year()+1 {
+		return 0
+	}
+	return year
+}
+
+func isYear(clauses []Clause, i int) bool {
+	return yearOf(clauses, i) != 0
+}
+
+// isTopRated reports whether clauses i and i+1 are "top rated" or "highest rated"
+func isTopRated(clauses []Clause, i int) bool {
+	word := plainWord(clauses, i)
+	return (word == "top" || word == "highest" || word == "best") && plainWord(clauses, i+1) == "rated"
+}
+
+// startsIntent reports whether an intent starts at clause i, which ends the
+// name after "by"
+func startsIntent(clauses []Clause, i int) bool {
+	word := plainWord(clauses, i)
+	return typeWords[word] != "" || word == "rated" || isYear(clauses, i) ||
+		((word == "from" || word == "in") && isYear(clauses, i+1)) || isTopRated(clauses, i)
+}
+
+// byClause searches the name made of the clauses' words as a phrase in the
+// fields people of the type are credited in
+func byClause(name []Clause, docType string) Clause {
+	var words []string
+	for _, clause := range name {
+		words = append(words, clause.Operands[0].Words...)
+	}
+
+	var clause Clause
+	for _, field := range byScopes[docType] {
+		clause.Operands = append(clause.Operands, Operand{
+			Field:  field,
+			Words:  words,
+			Prefix: len(words) == 1 && name[0].Operands[0].Prefix,
+			Pos:    name[0].Operands[0].Pos,
+			quoted: len(words) > 1,
+		})
+	}
+	return clause
+}
+
+// describe explains the interpretation in a sentence
+func (in *Interpretation) describe() string {
+	var b strings.Builder
+	b.WriteString("Showing ")
+	if in.TopRated {
+		b.WriteString("top rated ")
+	}
+	switch in.Type {
+	case "book":
+		b.WriteString("books")
+	case "movie":
+		b.WriteString("movies")
+	default:
+		b.WriteString("results")
+	}
+	if in.Query != "" && in.By != in.Query {
+		fmt.Fprintf(&b, " for %q", in.Query)
+	}
+	if in.By != "" {
+		fmt.Fprintf(&b, " by %s", in.By)
+	}
+	if in.Year != 0 {
+		fmt.Fprintf(&b, " from %d", in.Year)
+	}
+	if in.MinRating != 0 {
+		fmt.Fprintf(&b, " rated above %g", in.MinRating)
+	}
+	if in.PreferYear != 0 {
+		fmt.Fprintf(&b, ", from %d first", in.PreferYear)
+	}
+	return b.String()
+}
+
+// Filter returns the filter with the interpreted type, year and rating
+// added. Values set in the filter take precedence.
+func (in *Interpretation) Filter(base *Filter) *Filter {
+	var f Filter
+	if base != nil {
+		f = *base
+	}
+	if f.Type == "" {
+		f.Type = in.Type
+	}
+	if f.Year == nil && in.Year != 0 {
+		year := in.Year
+		f.Year = &IntRange{Min: &year, Max: &year}
+	}
+	if f.Rating == nil && in.MinRating != 0 {
+		rating := in.MinRating
+		f.Rating = &FloatRange{Min: &rating}
+	}
+	if f.Empty() {
+		return nil
+	}
+	return &f
+}
+
+// Boosts returns the ranking stages that favor the preferred year and
+// highly rated results
+func (in *Interpretation) Boosts() []Stage {
+	var stages []Stage
+	if in.PreferYear != 0 {
+		stages = append(stages, Stage{Scorer: YearScorer{Year: in.PreferYear}, Weight: preferYearWeight})
+	}
+	if in.TopRated {
+		stages = append(stages, Stage{Scorer: RatingScorer{}, Weight: topRatedWeight})
+	}
+	return stages
+}
+
+// Browse reports whether no words are left to search for, so the results are
+// every document that passes the filter and none of the excluded clauses
+func (in *Interpretation) Browse() bool {
+	return len(in.Expr.positive()) == 0
+}
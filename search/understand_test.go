@@ -0,0 +1,75 @@
+package search
+
+import "testing"
+
+func TestUnderstand(t *testing.T) {
+	tests := []struct {
+		query       string
+		want        string // the rewritten query, or "-" if nothing is recognized
+		docType     string
+		year        int
+		preferYear  int
+		by          string
+		minRating   float64
+		topRated    bool
+		description string
+	}{
+		{query: "movies 2017", want: "", docType: "movie", year: 2017, description: "Showing movies from 2017"},
+		{query: "books by tolkien", want: "author:tolkien", docType: "book", by: "tolkien", description: "Showing books by tolkien"},
+		{query: "movies by peter jackson", want: `director:"peter jackson"`, docType: "movie", by: "peter jackson", description: "Showing movies by peter jackson"},
+		{query: "top rated comedy", want: "comedy", topRated: true, description: `Showing top rated results for "comedy"`},
+		{query: "comedy rated above 7.5", want: `"comedy"`, minRating: 7.5, description: `Showing results for "comedy" rated above 7.5`},
+		{query: "batman 1989", want: `"batman"`, preferYear: 1989, description: `Showing results for "batman", from 1989 first`},
+		{query: "horror from 1999", want: `"horror"`, year: 1999, description: `Showing results for "horror" from 1999`},
+		{query: "harry potter books", want: `harry "potter"`, docType: "book", description: `Showing books for "harry potter"`},
+		{query: "1984", want: "-"},
+		{query: "2001 a space odyssey", want: "-"},
+		{query: "the jungle book", want: "-"},
+		{query: "stand by me", want: "-"},
+		{query: `"movies" -books`, want: "-"},
+	}
+	for _, test := range tests {
+		expr, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) returned error %v", test.query, err)
+		}
+		in := Understand(expr)
+		if test.want == "-" {
+			if in != nil {
+				t.Errorf("Understand(%q) = %+v, want nil", test.query, in)
+			}
+			continue
+		}
+		if in == nil {
+			t.Errorf("Understand(%q) = nil", test.query)
+			continue
+		}
+		if got := in.Expr.String(); got != test.want {
+			t.Errorf("Understand(%q) query = %q, want %q", test.query, got, test.want)
+		}
+		if in.Type != test.docType || in.Year != test.year || in.PreferYear != test.preferYear ||
+			in.By != test.by || in.MinRating != test.minRating || in.TopRated != test.topRated {
+			t.Errorf("Understand(%q) = %+v", test.query, in)
+		}
+		if in.Description != test.description {
+			t.Errorf("Understand(%q) description = %q, want %q", test.query, in.Description, test.description)
+		}
+	}
+}
+
+func TestInterpretationFilter(t *testing.T) {
+	expr, _ := ParseQuery("books 2005")
+	in := Understand(expr)
+
+	min := 1990
+	f := in.Filter(&Filter{Year: &IntRange{Min: &min}})
+	if f.Type != "book" || *f.Year.Min != 1990 || f.Year.Max != nil {
+		t.Errorf("Filter kept %+v, want the type added and the request's year range kept", f)
+	}
+
+	book := &Document{Type: "book", Year: 2005}
+	movie := &Document{Type: "movie", Year: 2005}
+	if f := in.Filter(nil); !f.Match(book) || f.Match(movie) || f.Match(&Document{Type: "book", Year: 2006}) {
+		t.Errorf("Filter(nil) = %+v does not select the books of 2005", f)
+	}
+}